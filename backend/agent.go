@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// AgentProvider describes a CLI coding agent and the model endpoint it talks to.
+// The command runs inside the cloned repository with these variables set:
+//
+//	AGENT_PROMPT       the task for the agent
+//	AGENT_PROMPT_FILE  file holding the same task, for agents that read it from one
+//	AGENT_MODEL        model name
+//	AGENT_BASE_URL     OpenAI-compatible API base URL
+//	AGENT_API_KEY      value of the server env var named by api_key_env
+type AgentProvider struct {
+	Name           string   `json:"name"`
+	Command        string   `json:"command"`                   // shell command, e.g. opencode run "$AGENT_PROMPT"
+	ConfigPath     string   `json:"config_path,omitempty"`     // config file written relative to $HOME
+	ConfigTemplate string   `json:"config_template,omitempty"` // text/template with .Model and .BaseURL
+	Model          string   `json:"model,omitempty"`
+	BaseURL        string   `json:"base_url,omitempty"`
+	APIKeyEnv      string   `json:"api_key_env,omitempty"` // server env var holding the API key
+	Secrets        []string `json:"secrets,omitempty"`     // other server env vars passed through to the agent
+}
+
+// AgentSelection picks a provider for a service and optionally overrides its
+// model and endpoint
+type AgentSelection struct {
+	Provider string `json:"provider,omitempty"` // default provider when empty
+	Model    string `json:"model,omitempty"`
+	BaseURL  string `json:"base_url,omitempty"`
+}
+
+// openCodeConfigTemplate configures OpenCode for any OpenAI-compatible endpoint
+const openCodeConfigTemplate = `{
+  "$schema": "https://opencode.ai/config.json",
+  "model": "highline/{{.Model}}",
+  "provider": {
+    "highline": {
+      "npm": "@ai-sdk/openai-compatible",
+      "name": "Highline",
+      "models": {
+        {{json .Model}}: {"name": {{json .Model}}}
+      },
+      "options": {
+        "baseURL": {{json .BaseURL}},
+        "apiKey": "{env:AGENT_API_KEY}"
+      }
+    }
+  }
+}
+`
+
+// builtinAgentProviders are available without configuration
+var builtinAgentProviders = []AgentProvider{
+	{
+		Name:           "opencode",
+		Command:        `opencode run "$AGENT_PROMPT"`,
+		ConfigPath:     ".config/opencode/opencode.json",
+		ConfigTemplate: openCodeConfigTemplate,
+		Model:          "gpt-oss-120b",
+		BaseURL:        "https://api.cerebras.ai/v1",
+		APIKeyEnv:      "CEREBRAS_API_KEY",
+	},
+	{
+		Name: "aider",
+		Command: `OPENAI_API_BASE="$AGENT_BASE_URL" OPENAI_API_KEY="$AGENT_API_KEY" ` +
+			`aider --yes-always --no-auto-commits --no-git-commit-verify --model "openai/$AGENT_MODEL" --message-file "$AGENT_PROMPT_FILE"`,
+		Model:     "gpt-4o",
+		BaseURL:   "https://api.openai.com/v1",
+		APIKeyEnv: "OPENAI_API_KEY",
+	},
+}
+
+var agentConfigFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// Validate checks that the provider can be used
+func (p *AgentProvider) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("agent provider name is required")
+	}
+	if p.Command == "" {
+		return fmt.Errorf("agent provider %q: command is required", p.Name)
+	}
+	if (p.ConfigPath == "") != (p.ConfigTemplate == "") {
+		return fmt.Errorf("agent provider %q: config_path and config_template must be set together", p.Name)
+	}
+	if p.ConfigTemplate != "" {
+		if _, err := template.New(p.Name).Funcs(agentConfigFuncs).Parse(p.ConfigTemplate); err != nil {
+			return fmt.Errorf("agent provider %q: invalid config_template: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// renderConfig renders the provider's config file
+func (p *AgentProvider) renderConfig() (string, error) {
+	if p.ConfigTemplate == "" {
+		return "", nil
+	}
+	tmpl, err := template.New(p.Name).Funcs(agentConfigFuncs).Parse(p.ConfigTemplate)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, p); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Env returns the environment the agent job needs. Fails if a required secret
+// is not set on the server.
+func (p *AgentProvider) Env() ([]string, error) {
+	config, err := p.renderConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s config: %w", p.Name, err)
+	}
+
+	env := []string{
+		"AGENT_NAME=" + p.Name,
+		"AGENT_COMMAND=" + p.Command,
+		"AGENT_MODEL=" + p.Model,
+		"AGENT_BASE_URL=" + p.BaseURL,
+		"AGENT_CONFIG_PATH=" + p.ConfigPath,
+		"AGENT_CONFIG=" + config,
+	}
+
+	if p.APIKeyEnv != "" {
+		key := os.Getenv(p.APIKeyEnv)
+		if key == "" {
+			return nil, fmt.Errorf("%s not set", p.APIKeyEnv)
+		}
+		env = append(env, "AGENT_API_KEY="+key)
+	}
+
+	for _, name := range p.Secrets {
+		value := os.Getenv(name)
+		if value == "" {
+			return nil, fmt.Errorf("%s not set", name)
+		}
+		env = append(env, name+"="+value)
+	}
+	return env, nil
+}
+
+// AgentRegistry holds the configured agent providers
+type AgentRegistry struct {
+	providers   map[string]AgentProvider
+	defaultName string
+}
+
+// NewAgentRegistryFromEnv loads the built-in providers, any providers from
+// AGENT_PROVIDERS_FILE (a JSON array; same names replace built-ins) and the
+// default from AGENT_PROVIDER, AGENT_MODEL and AGENT_BASE_URL
+func NewAgentRegistryFromEnv() (*AgentRegistry, error) {
+	reg := &AgentRegistry{
+		providers:   make(map[string]AgentProvider),
+		defaultName: "opencode",
+	}
+	for _, p := range builtinAgentProviders {
+		reg.providers[p.Name] = p
+	}
+
+	if path := os.Getenv("AGENT_PROVIDERS_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read AGENT_PROVIDERS_FILE: %w", err)
+		}
+		var custom []AgentProvider
+		if err := json.Unmarshal(data, &custom); err != nil {
+			return nil, fmt.Errorf("invalid AGENT_PROVIDERS_FILE: %w", err)
+		}
+		for _, p := range custom {
+			if err := p.Validate(); err != nil {
+				return nil, err
+			}
+			reg.providers[p.Name] = p
+		}
+	}
+
+	if name := os.Getenv("AGENT_PROVIDER"); name != "" {
+		reg.defaultName = name
+	}
+	def, ok := reg.providers[reg.defaultName]
+	if !ok {
+		return nil, fmt.Errorf("unknown AGENT_PROVIDER %q", reg.defaultName)
+	}
+	if model := os.Getenv("AGENT_MODEL"); model != "" {
+		def.Model = model
+	}
+	if baseURL := os.Getenv("AGENT_BASE_URL"); baseURL != "" {
+		def.BaseURL = baseURL
+	}
+	reg.providers[def.Name] = def
+
+	slog.Info("Agent providers loaded",
+		"providers", reg.Names(),
+		"default", def.Name,
+		"model", def.Model,
+		"base_url", def.BaseURL,
+	)
+	return reg, nil
+}
+
+// Names returns the provider names, sorted
+func (r *AgentRegistry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Has reports whether a provider is configured
+func (r *AgentRegistry) Has(name string) bool {
+	_, ok := r.providers[name]
+	return ok
+}
+
+// Resolve returns the provider for a selection, applying its overrides.
+// A nil selection resolves to the default provider.
+func (r *AgentRegistry) Resolve(sel *AgentSelection) (AgentProvider, error) {
+	name := r.defaultName
+	if sel != nil && sel.Provider != "" {
+		name = sel.Provider
+	}
+
+	provider, ok := r.providers[name]
+	if !ok {
+		return AgentProvider{}, fmt.Errorf("unknown agent provider %q", name)
+	}
+	if sel != nil {
+		if sel.Model != "" {
+			provider.Model = sel.Model
+		}
+		if sel.BaseURL != "" {
+			// The API key is the server's, so it may only go to the host it was issued for
+			if provider.APIKeyEnv != "" && urlHost(sel.BaseURL) != urlHost(provider.BaseURL) {
+				return AgentProvider{}, fmt.Errorf("agent provider %q: base_url must use host %s, where its %s key is valid",
+					name, urlHost(provider.BaseURL), provider.APIKeyEnv)
+			}
+			provider.BaseURL = sel.BaseURL
+		}
+	}
+	return provider, nil
+}
+
+// urlHost returns the host[:port] of a URL, or "" if it does not parse
+func urlHost(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
@@ -0,0 +1,534 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// AlertEvent is what an alert is about
+type AlertEvent string
+
+const (
+	AlertServiceDown          AlertEvent = "service_down"
+	AlertServiceError         AlertEvent = "service_error"
+	AlertServiceRecovered     AlertEvent = "service_recovered"
+	AlertRemediationSucceeded AlertEvent = "remediation_succeeded"
+	AlertRemediationFailed    AlertEvent = "remediation_failed"
+	AlertTest                 AlertEvent = "test"
+)
+
+var knownAlertEvents = map[AlertEvent]bool{
+	AlertServiceDown:          true,
+	AlertServiceError:         true,
+	AlertServiceRecovered:     true,
+	AlertRemediationSucceeded: true,
+	AlertRemediationFailed:    true,
+	AlertTest:                 true,
+}
+
+// ErrUnknownNotifier is returned for a test alert to a notifier that is not configured
+var ErrUnknownNotifier = errors.New("unknown notifier")
+
+// alertTimeout bounds a single delivery to a notifier
+const alertTimeout = 30 * time.Second
+
+// Alert is an event worth telling someone about. It is the data notifier
+// templates are rendered with.
+type Alert struct {
+	Event          AlertEvent    `json:"event"`
+	Service        string        `json:"service"`
+	Status         ServiceStatus `json:"status,omitempty"`
+	PreviousStatus ServiceStatus `json:"previous_status,omitempty"`
+	Title          string        `json:"title"`             // one line, e.g. "user-service is down"
+	Message        string        `json:"message,omitempty"` // error, timeout reason or remediation summary
+	Environment    string        `json:"environment,omitempty"`
+	Owner          string        `json:"owner,omitempty"`
+	Tags           []string      `json:"tags,omitempty"`
+	GitHubRepo     string        `json:"github_repo,omitempty"`
+	RemediationID  string        `json:"remediation_id,omitempty"`
+	PRURL          string        `json:"pr_url,omitempty"`
+	Time           time.Time     `json:"time"`
+}
+
+// AlertMessage is an alert rendered with a notifier's templates
+type AlertMessage struct {
+	Alert
+	Subject string `json:"subject"` // title_template output; email subject
+	Text    string `json:"text"`    // text_template output; message body
+}
+
+// Notifier delivers alerts to one destination
+type Notifier interface {
+	Notify(ctx context.Context, msg AlertMessage) error
+}
+
+// NotifierType selects a Notifier implementation
+type NotifierType string
+
+const (
+	NotifierWebhook NotifierType = "webhook" // JSON POST of the alert
+	NotifierSlack   NotifierType = "slack"   // Slack-compatible incoming webhook
+	NotifierSMTP    NotifierType = "smtp"    // email
+)
+
+// NotifierConfig declares a notifier in ALERTS_FILE. Secrets are named by
+// server env vars, never written in the file.
+type NotifierConfig struct {
+	Name          string            `json:"name"`
+	Type          NotifierType      `json:"type"`
+	URL           string            `json:"url,omitempty"`            // webhook and slack
+	URLEnv        string            `json:"url_env,omitempty"`        // env var holding the URL instead, e.g. a Slack webhook
+	Headers       map[string]string `json:"headers,omitempty"`        // webhook: extra request headers
+	SecretEnv     string            `json:"secret_env,omitempty"`     // webhook: env var holding an HMAC signing secret
+	SMTPAddr      string            `json:"smtp_addr,omitempty"`      // smtp: host:port
+	From          string            `json:"from,omitempty"`           // smtp
+	To            []string          `json:"to,omitempty"`             // smtp
+	Username      string            `json:"username,omitempty"`       // smtp: PLAIN auth user
+	PasswordEnv   string            `json:"password_env,omitempty"`   // smtp: env var holding the password
+	TitleTemplate string            `json:"title_template,omitempty"` // text/template over Alert; default "[Highline] {{.Title}}"
+	TextTemplate  string            `json:"text_template,omitempty"`  // text/template over Alert
+}
+
+// Default notifier templates
+const (
+	defaultAlertTitleTemplate = `[Highline] {{.Title}}`
+	defaultAlertTextTemplate  = `{{.Title}}
+{{if .Message}}
+{{.Message}}
+{{end}}{{if .PRURL}}
+Pull request: {{.PRURL}}
+{{end}}{{if .Environment}}
+Environment: {{.Environment}}{{end}}{{if .Owner}}
+Owner: {{.Owner}}{{end}}`
+)
+
+// Validate checks that the notifier can be built
+func (c *NotifierConfig) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("notifier name is required")
+	}
+	switch c.Type {
+	case NotifierWebhook, NotifierSlack:
+		if (c.URL == "") == (c.URLEnv == "") {
+			return fmt.Errorf("notifier %q: exactly one of url and url_env is required", c.Name)
+		}
+		if c.URL != "" && !strings.HasPrefix(c.URL, "https://") && !strings.HasPrefix(c.URL, "http://") {
+			return fmt.Errorf("notifier %q: url must be http(s)", c.Name)
+		}
+	case NotifierSMTP:
+		if c.SMTPAddr == "" || c.From == "" || len(c.To) == 0 {
+			return fmt.Errorf("notifier %q: smtp_addr, from and to are required", c.Name)
+		}
+		for _, addr := range append([]string{c.From}, c.To...) {
+			if _, err := mail.ParseAddress(addr); err != nil {
+				return fmt.Errorf("notifier %q: invalid address %q", c.Name, addr)
+			}
+		}
+		if (c.Username == "") != (c.PasswordEnv == "") {
+			return fmt.Errorf("notifier %q: username and password_env must be set together", c.Name)
+		}
+	default:
+		return fmt.Errorf("notifier %q: type must be webhook, slack or smtp", c.Name)
+	}
+	if _, err := template.New(c.Name).Parse(c.TitleTemplate); err != nil {
+		return fmt.Errorf("notifier %q: invalid title_template: %w", c.Name, err)
+	}
+	if _, err := template.New(c.Name).Parse(c.TextTemplate); err != nil {
+		return fmt.Errorf("notifier %q: invalid text_template: %w", c.Name, err)
+	}
+	return nil
+}
+
+// url returns the configured URL, from url_env if set
+func (c *NotifierConfig) url() (string, error) {
+	if c.URLEnv == "" {
+		return c.URL, nil
+	}
+	url := os.Getenv(c.URLEnv)
+	if url == "" {
+		return "", fmt.Errorf("%s not set", c.URLEnv)
+	}
+	return url, nil
+}
+
+// build creates the notifier
+func (c *NotifierConfig) build() (Notifier, error) {
+	switch c.Type {
+	case NotifierWebhook:
+		url, err := c.url()
+		if err != nil {
+			return nil, err
+		}
+		secret := ""
+		if c.SecretEnv != "" {
+			if secret = os.Getenv(c.SecretEnv); secret == "" {
+				return nil, fmt.Errorf("%s not set", c.SecretEnv)
+			}
+		}
+		return NewWebhookNotifier(url, c.Headers, secret), nil
+	case NotifierSlack:
+		url, err := c.url()
+		if err != nil {
+			return nil, err
+		}
+		return NewSlackNotifier(url), nil
+	default:
+		password := ""
+		if c.PasswordEnv != "" {
+			if password = os.Getenv(c.PasswordEnv); password == "" {
+				return nil, fmt.Errorf("%s not set", c.PasswordEnv)
+			}
+		}
+		return NewSMTPNotifier(c.SMTPAddr, c.From, c.To, c.Username, password), nil
+	}
+}
+
+// AlertRoute sends matching alerts to a set of notifiers. Empty match lists
+// match everything.
+type AlertRoute struct {
+	Services  []string     `json:"services,omitempty"` // names or path.Match patterns, e.g. "billing-*"
+	Tags      []string     `json:"tags,omitempty"`     // service has any of them
+	Events    []AlertEvent `json:"events,omitempty"`
+	Notifiers []string     `json:"notifiers"`
+}
+
+// matches reports whether the route applies to an alert
+func (r *AlertRoute) matches(alert Alert) bool {
+	if len(r.Events) > 0 && !containsEvent(r.Events, alert.Event) {
+		return false
+	}
+	if len(r.Services) > 0 {
+		matched := false
+		for _, pattern := range r.Services {
+			if ok, _ := path.Match(pattern, alert.Service); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(r.Tags) > 0 {
+		matched := false
+		for _, tag := range r.Tags {
+			for _, have := range alert.Tags {
+				if tag == have {
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func containsEvent(events []AlertEvent, event AlertEvent) bool {
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// alertsFile is the format of ALERTS_FILE
+type alertsFile struct {
+	Notifiers []NotifierConfig `json:"notifiers"`
+	Routes    []AlertRoute     `json:"routes,omitempty"` // none sends every alert to every notifier
+}
+
+// alertNotifier is a built notifier with its parsed templates
+type alertNotifier struct {
+	name     string
+	notifier Notifier
+	title    *template.Template
+	text     *template.Template
+}
+
+// Alerter routes alerts to notifiers. The zero value sends nothing.
+type Alerter struct {
+	notifiers map[string]*alertNotifier
+	routes    []AlertRoute
+}
+
+// NewAlerterFromEnv loads notifiers and routes from ALERTS_FILE. Without it
+// alerting is off.
+func NewAlerterFromEnv() (*Alerter, error) {
+	a := &Alerter{notifiers: make(map[string]*alertNotifier)}
+
+	filePath := os.Getenv("ALERTS_FILE")
+	if filePath == "" {
+		slog.Info("ALERTS_FILE not set - alerting is disabled")
+		return a, nil
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ALERTS_FILE: %w", err)
+	}
+	var file alertsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid ALERTS_FILE: %w", err)
+	}
+
+	for _, cfg := range file.Notifiers {
+		if err := cfg.Validate(); err != nil {
+			return nil, err
+		}
+		if _, exists := a.notifiers[cfg.Name]; exists {
+			return nil, fmt.Errorf("duplicate notifier %q", cfg.Name)
+		}
+		notifier, err := cfg.build()
+		if err != nil {
+			return nil, fmt.Errorf("notifier %q: %w", cfg.Name, err)
+		}
+		titleTemplate, textTemplate := cfg.TitleTemplate, cfg.TextTemplate
+		if titleTemplate == "" {
+			titleTemplate = defaultAlertTitleTemplate
+		}
+		if textTemplate == "" {
+			textTemplate = defaultAlertTextTemplate
+		}
+		a.notifiers[cfg.Name] = &alertNotifier{
+			name:     cfg.Name,
+			notifier: notifier,
+			title:    template.Must(template.New(cfg.Name).Parse(titleTemplate)),
+			text:     template.Must(template.New(cfg.Name).Parse(textTemplate)),
+		}
+	}
+
+	for i, route := range file.Routes {
+		if len(route.Notifiers) == 0 {
+			return nil, fmt.Errorf("route %d: notifiers is required", i)
+		}
+		for _, name := range route.Notifiers {
+			if _, exists := a.notifiers[name]; !exists {
+				return nil, fmt.Errorf("route %d: unknown notifier %q", i, name)
+			}
+		}
+		for _, event := range route.Events {
+			if !knownAlertEvents[event] {
+				return nil, fmt.Errorf("route %d: unknown event %q", i, event)
+			}
+		}
+		for _, pattern := range route.Services {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("route %d: invalid service pattern %q", i, pattern)
+			}
+		}
+	}
+	a.routes = file.Routes
+
+	slog.Info("Alert notifiers loaded",
+		"notifiers", a.Names(),
+		"routes", len(a.routes),
+	)
+	return a, nil
+}
+
+// Names returns the configured notifiers, sorted
+func (a *Alerter) Names() []string {
+	names := make([]string, 0, len(a.notifiers))
+	for name := range a.notifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// route returns the notifiers an alert goes to, each once
+func (a *Alerter) route(alert Alert) []*alertNotifier {
+	if len(a.routes) == 0 {
+		var all []*alertNotifier
+		for _, name := range a.Names() {
+			all = append(all, a.notifiers[name])
+		}
+		return all
+	}
+
+	var targets []*alertNotifier
+	seen := make(map[string]bool)
+	for _, route := range a.routes {
+		if !route.matches(alert) {
+			continue
+		}
+		for _, name := range route.Notifiers {
+			if !seen[name] {
+				seen[name] = true
+				targets = append(targets, a.notifiers[name])
+			}
+		}
+	}
+	return targets
+}
+
+// Send delivers an alert to the notifiers its routes select. Delivery happens
+// in the background, so Send is safe to call from store hooks.
+func (a *Alerter) Send(alert Alert) {
+	if a == nil {
+		return
+	}
+	if alert.Time.IsZero() {
+		alert.Time = time.Now()
+	}
+	for _, target := range a.route(alert) {
+		go func(target *alertNotifier) {
+			if err := a.deliver(target, alert); err != nil {
+				slog.Warn("[ALERT] Delivery failed",
+					"notifier", target.name,
+					"event", alert.Event,
+					"service", alert.Service,
+					"error", err,
+				)
+			}
+		}(target)
+	}
+}
+
+// Test sends a test alert to one notifier and waits for the result
+func (a *Alerter) Test(name string) error {
+	target, exists := a.notifiers[name]
+	if !exists {
+		return fmt.Errorf("%w %q", ErrUnknownNotifier, name)
+	}
+	return a.deliver(target, Alert{
+		Event:   AlertTest,
+		Service: "highline",
+		Title:   "Test alert from Highline",
+		Message: "Notifier " + name + " is configured correctly.",
+		Time:    time.Now(),
+	})
+}
+
+// deliver renders an alert with the notifier's templates and sends it
+func (a *Alerter) deliver(target *alertNotifier, alert Alert) error {
+	msg := AlertMessage{Alert: alert}
+
+	var b strings.Builder
+	if err := target.title.Execute(&b, alert); err != nil {
+		return fmt.Errorf("title_template: %w", err)
+	}
+	// Subjects end up in mail headers
+	msg.Subject = strings.Join(strings.Fields(b.String()), " ")
+
+	b.Reset()
+	if err := target.text.Execute(&b, alert); err != nil {
+		return fmt.Errorf("text_template: %w", err)
+	}
+	msg.Text = strings.TrimSpace(b.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), alertTimeout)
+	defer cancel()
+	if err := target.notifier.Notify(ctx, msg); err != nil {
+		return err
+	}
+	slog.Info("[ALERT] Sent",
+		"notifier", target.name,
+		"event", alert.Event,
+		"service", alert.Service,
+	)
+	return nil
+}
+
+// newServiceAlert fills an alert with a service's metadata
+func newServiceAlert(event AlertEvent, service Service, title, message string) Alert {
+	return Alert{
+		Event:       event,
+		Service:     service.Name,
+		Status:      service.Status,
+		Title:       title,
+		Message:     message,
+		Environment: service.Environment,
+		Owner:       service.Owner,
+		Tags:        service.Tags,
+		GitHubRepo:  service.GitHubRepo,
+		Time:        time.Now(),
+	}
+}
+
+// statusChangeAlert returns the alert for a service status change, if any.
+// A service's first status out of pending is not a recovery.
+func statusChangeAlert(change StatusChange) (Alert, bool) {
+	var alert Alert
+	switch {
+	case change.To == StatusDown:
+		alert = newServiceAlert(AlertServiceDown, change.Service, change.Service.Name+" is down", change.Reason)
+	case change.To == StatusError:
+		alert = newServiceAlert(AlertServiceError, change.Service, change.Service.Name+" is reporting errors", change.Reason)
+	case change.To == StatusHealthy && (change.From == StatusDown || change.From == StatusError):
+		alert = newServiceAlert(AlertServiceRecovered, change.Service, change.Service.Name+" recovered", "Previously "+string(change.From)+".")
+	default:
+		return Alert{}, false
+	}
+	alert.PreviousStatus = change.From
+	return alert, true
+}
+
+// remediationAlert returns the alert for a finished remediation. Only a
+// successful agent report counts as success: an agent that exited cleanly
+// without reporting has failed. Cancelled remediations are not alerted on.
+func remediationAlert(record *RemediationRecord, service Service, err error) (Alert, bool) {
+	if errors.Is(err, ErrRemediationCancelled) || record.Status == RemediationCancelled {
+		return Alert{}, false
+	}
+
+	report := record.AgentReport
+	succeeded := err == nil && report != nil && report.Success
+
+	message := ""
+	switch {
+	case report != nil && report.Summary != "":
+		message = report.Summary
+	case err != nil:
+		message = err.Error()
+	case record.ErrorMessage != "":
+		message = record.ErrorMessage
+	case report == nil:
+		message = "The agent exited without sending a report"
+	}
+
+	var alert Alert
+	if succeeded {
+		alert = newServiceAlert(AlertRemediationSucceeded, service, "Remediation succeeded for "+record.ServiceName, message)
+	} else {
+		alert = newServiceAlert(AlertRemediationFailed, service, "Remediation failed for "+record.ServiceName, message)
+	}
+	alert.Service = record.ServiceName
+	alert.RemediationID = record.ID
+	alert.PRURL = record.PRURL
+	return alert, true
+}
+
+// alertStatusChange is the status hook that alerts on down, error and recovery
+func (app *App) alertStatusChange(change StatusChange) {
+	if alert, ok := statusChangeAlert(change); ok {
+		app.alerts.Send(alert)
+	}
+}
+
+// alertRemediationOutcome alerts on a finished remediation
+func (app *App) alertRemediationOutcome(remediationID string, err error) {
+	record, exists := app.remediationStore.Get(remediationID)
+	if !exists {
+		return
+	}
+	var service Service
+	if current, ok := app.store.GetService(record.ServiceName); ok {
+		service = *current
+	}
+	if alert, ok := remediationAlert(record, service, err); ok {
+		app.alerts.Send(alert)
+	}
+}
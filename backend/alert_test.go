@@ -0,0 +1,405 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestAlerter loads an Alerter from an ALERTS_FILE with the given contents
+func newTestAlerter(t *testing.T, file string) (*Alerter, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "alerts.json")
+	if err := os.WriteFile(path, []byte(file), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ALERTS_FILE", path)
+	return NewAlerterFromEnv()
+}
+
+func TestAlertRouteMatches(t *testing.T) {
+	alert := Alert{Event: AlertServiceDown, Service: "billing-api", Tags: []string{"payments", "tier-1"}}
+
+	tests := []struct {
+		name  string
+		route AlertRoute
+		want  bool
+	}{
+		{"empty matches all", AlertRoute{}, true},
+		{"event", AlertRoute{Events: []AlertEvent{AlertServiceError, AlertServiceDown}}, true},
+		{"other event", AlertRoute{Events: []AlertEvent{AlertServiceRecovered}}, false},
+		{"exact service", AlertRoute{Services: []string{"billing-api"}}, true},
+		{"service glob", AlertRoute{Services: []string{"auth-*", "billing-*"}}, true},
+		{"other service", AlertRoute{Services: []string{"billing"}}, false},
+		{"any tag", AlertRoute{Tags: []string{"internal", "tier-1"}}, true},
+		{"no tag", AlertRoute{Tags: []string{"internal"}}, false},
+		{"all criteria", AlertRoute{Services: []string{"billing-*"}, Tags: []string{"payments"}, Events: []AlertEvent{AlertServiceDown}}, true},
+		{"one criterion fails", AlertRoute{Services: []string{"billing-*"}, Tags: []string{"payments"}, Events: []AlertEvent{AlertTest}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.route.matches(alert); got != tt.want {
+			t.Errorf("%s: matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAlerterRoute(t *testing.T) {
+	a, err := newTestAlerter(t, `{
+		"notifiers": [
+			{"name": "oncall", "type": "webhook", "url": "http://127.0.0.1/oncall"},
+			{"name": "billing", "type": "slack", "url": "http://127.0.0.1/billing"},
+			{"name": "audit", "type": "webhook", "url": "http://127.0.0.1/audit"}
+		],
+		"routes": [
+			{"events": ["service_down"], "notifiers": ["oncall"]},
+			{"services": ["billing-*"], "notifiers": ["billing", "oncall"]},
+			{"tags": ["audited"], "notifiers": ["audit"]}
+		]
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := func(targets []*alertNotifier) []string {
+		var out []string
+		for _, target := range targets {
+			out = append(out, target.name)
+		}
+		return out
+	}
+	tests := []struct {
+		alert Alert
+		want  []string
+	}{
+		{Alert{Event: AlertServiceDown, Service: "billing-api"}, []string{"oncall", "billing"}},
+		{Alert{Event: AlertServiceError, Service: "billing-api"}, []string{"billing", "oncall"}},
+		{Alert{Event: AlertServiceError, Service: "users", Tags: []string{"audited"}}, []string{"audit"}},
+		{Alert{Event: AlertServiceRecovered, Service: "users"}, nil},
+	}
+	for _, tt := range tests {
+		if got := names(a.route(tt.alert)); !slices.Equal(got, tt.want) {
+			t.Errorf("route(%s, %s) = %v, want %v", tt.alert.Event, tt.alert.Service, got, tt.want)
+		}
+	}
+
+	// Without routes every notifier gets every alert
+	a.routes = nil
+	if got := names(a.route(Alert{Event: AlertServiceRecovered})); !slices.Equal(got, []string{"audit", "billing", "oncall"}) {
+		t.Errorf("route without routes = %v", got)
+	}
+}
+
+func TestNewAlerterFromEnvErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		errPart string
+	}{
+		{"unknown notifier", `{"notifiers": [], "routes": [{"notifiers": ["x"]}]}`, `unknown notifier "x"`},
+		{"unknown event", `{"notifiers": [{"name": "n", "type": "webhook", "url": "http://x"}],
+			"routes": [{"events": ["exploded"], "notifiers": ["n"]}]}`, `unknown event "exploded"`},
+		{"bad pattern", `{"notifiers": [{"name": "n", "type": "webhook", "url": "http://x"}],
+			"routes": [{"services": ["["], "notifiers": ["n"]}]}`, "invalid service pattern"},
+		{"duplicate", `{"notifiers": [{"name": "n", "type": "webhook", "url": "http://x"},
+			{"name": "n", "type": "webhook", "url": "http://y"}]}`, "duplicate notifier"},
+		{"bad template", `{"notifiers": [{"name": "n", "type": "webhook", "url": "http://x", "text_template": "{{.Title"}]}`, "invalid text_template"},
+		{"missing secret", `{"notifiers": [{"name": "n", "type": "webhook", "url": "http://x", "secret_env": "HIGHLINE_TEST_UNSET"}]}`, "HIGHLINE_TEST_UNSET not set"},
+	}
+	for _, tt := range tests {
+		_, err := newTestAlerter(t, tt.file)
+		if err == nil || !strings.Contains(err.Error(), tt.errPart) {
+			t.Errorf("%s: err = %v, want it to contain %q", tt.name, err, tt.errPart)
+		}
+	}
+}
+
+// recordingNotifier keeps the messages it is given
+type recordingNotifier struct {
+	messages []AlertMessage
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, msg AlertMessage) error {
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+func TestAlertTemplates(t *testing.T) {
+	a, err := newTestAlerter(t, `{"notifiers": [
+		{"name": "default", "type": "webhook", "url": "http://127.0.0.1/"},
+		{"name": "custom", "type": "webhook", "url": "http://127.0.0.1/",
+			"title_template": "{{.Service}}:\n{{.Event}}",
+			"text_template": "{{.Title}} ({{.PreviousStatus}} -> {{.Status}}){{range .Tags}} #{{.}}{{end}}"}
+	]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorders := make(map[string]*recordingNotifier)
+	for name, target := range a.notifiers {
+		recorders[name] = &recordingNotifier{}
+		target.notifier = recorders[name]
+	}
+
+	change := StatusChange{
+		Service: Service{Name: "billing-api", Status: StatusDown, Environment: "prod", Owner: "payments-team", Tags: []string{"tier-1"}},
+		From:    StatusHealthy,
+		To:      StatusDown,
+		Reason:  "No heartbeat for 2m0s",
+	}
+	alert, ok := statusChangeAlert(change)
+	if !ok {
+		t.Fatal("no alert for a service going down")
+	}
+	for name, target := range a.notifiers {
+		if err := a.deliver(target, alert); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+
+	msg := recorders["default"].messages[0]
+	if msg.Subject != "[Highline] billing-api is down" {
+		t.Errorf("default subject = %q", msg.Subject)
+	}
+	wantText := "billing-api is down\n\nNo heartbeat for 2m0s\n\nEnvironment: prod\nOwner: payments-team"
+	if msg.Text != wantText {
+		t.Errorf("default text = %q, want %q", msg.Text, wantText)
+	}
+
+	msg = recorders["custom"].messages[0]
+	if msg.Subject != "billing-api: service_down" {
+		t.Errorf("custom subject = %q, want the newline folded away", msg.Subject)
+	}
+	if msg.Text != "billing-api is down (healthy -> down) #tier-1" {
+		t.Errorf("custom text = %q", msg.Text)
+	}
+}
+
+func TestRemediationAlert(t *testing.T) {
+	service := Service{Name: "api"}
+	tests := []struct {
+		name    string
+		record  RemediationRecord
+		err     error
+		event   AlertEvent
+		message string
+	}{
+		{"success", RemediationRecord{Status: RemediationSuccess, AgentReport: &AgentReport{Success: true, Summary: "Fixed it"}},
+			nil, AlertRemediationSucceeded, "Fixed it"},
+		{"agent gave up", RemediationRecord{Status: RemediationFailed, AgentReport: &AgentReport{Summary: "Could not reproduce"}},
+			nil, AlertRemediationFailed, "Could not reproduce"},
+		{"no report", RemediationRecord{Status: RemediationSuccess},
+			nil, AlertRemediationFailed, "The agent exited without sending a report"},
+		{"error", RemediationRecord{Status: RemediationFailed},
+			errors.New("clone failed"), AlertRemediationFailed, "clone failed"},
+	}
+	for _, tt := range tests {
+		tt.record.ID, tt.record.ServiceName, tt.record.PRURL = "r1", "api", "https://github.com/acme/api/pull/1"
+		alert, ok := remediationAlert(&tt.record, service, tt.err)
+		if !ok {
+			t.Errorf("%s: no alert", tt.name)
+			continue
+		}
+		if alert.Event != tt.event || alert.Message != tt.message || alert.RemediationID != "r1" || alert.PRURL == "" {
+			t.Errorf("%s: alert = %+v", tt.name, alert)
+		}
+	}
+
+	cancelled := RemediationRecord{ID: "r2", ServiceName: "api", Status: RemediationCancelled}
+	if _, ok := remediationAlert(&cancelled, service, ErrRemediationCancelled); ok {
+		t.Error("alerted on a cancelled remediation")
+	}
+}
+
+func TestWebhookNotifierSigning(t *testing.T) {
+	type received struct {
+		msg    AlertMessage
+		header http.Header
+		err    error
+	}
+	requests := make(chan received, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var got received
+		got.header = r.Header
+		got.err = verifyCallback("webhook-secret", r.Header.Get(headerTimestamp), r.Header.Get(headerSignature), body, time.Now())
+		json.Unmarshal(body, &got.msg)
+		requests <- got
+		if got.err != nil {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	msg := AlertMessage{
+		Alert:   Alert{Event: AlertServiceError, Service: "api", Title: "api is reporting errors"},
+		Subject: "[Highline] api is reporting errors",
+		Text:    "panic: boom",
+	}
+
+	signed := NewWebhookNotifier(server.URL, map[string]string{"X-Team": "payments"}, "webhook-secret")
+	if err := signed.Notify(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	got := <-requests
+	if got.err != nil {
+		t.Errorf("signature did not verify: %v", got.err)
+	}
+	if got.header.Get("X-Team") != "payments" || got.header.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", got.header)
+	}
+	if got.msg.Service != "api" || got.msg.Event != AlertServiceError || got.msg.Subject != msg.Subject || got.msg.Text != msg.Text {
+		t.Errorf("body = %+v", got.msg)
+	}
+
+	wrongKey := NewWebhookNotifier(server.URL, nil, "other-secret")
+	err := wrongKey.Notify(context.Background(), msg)
+	<-requests
+	if err == nil || !strings.Contains(err.Error(), "webhook returned 401: bad signature") {
+		t.Errorf("wrong secret: err = %v", err)
+	}
+}
+
+func TestSlackNotifierEscapesText(t *testing.T) {
+	payloads := make(chan map[string]string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads <- payload
+	}))
+	defer server.Close()
+
+	msg := AlertMessage{Text: "<!channel> a & b <https://evil|click>"}
+	if err := NewSlackNotifier(server.URL).Notify(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	want := "&lt;!channel&gt; a &amp; b &lt;https://evil|click&gt;"
+	if got := (<-payloads)["text"]; got != want {
+		t.Errorf("text = %q, want %q", got, want)
+	}
+}
+
+// smtpSession is what the fake SMTP server saw
+type smtpSession struct {
+	auth string
+	from string
+	rcpt []string
+	data string
+}
+
+// fakeSMTPServer accepts one connection and plays the server side of an
+// SMTP conversation, offering AUTH PLAIN but not STARTTLS
+func fakeSMTPServer(t *testing.T) (string, <-chan smtpSession) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	sessions := make(chan smtpSession, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+		var session smtpSession
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 fake.localhost ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			verb, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(verb) {
+			case "EHLO":
+				tp.PrintfLine("250-fake.localhost")
+				tp.PrintfLine("250 AUTH PLAIN")
+			case "AUTH":
+				session.auth = arg
+				tp.PrintfLine("235 2.7.0 Authentication successful")
+			case "MAIL":
+				session.from = arg
+				tp.PrintfLine("250 OK")
+			case "RCPT":
+				session.rcpt = append(session.rcpt, arg)
+				tp.PrintfLine("250 OK")
+			case "DATA":
+				tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+				data, err := io.ReadAll(tp.DotReader())
+				if err != nil {
+					return
+				}
+				session.data = string(data)
+				tp.PrintfLine("250 OK: queued")
+			case "QUIT":
+				tp.PrintfLine("221 Bye")
+				sessions <- session
+				return
+			default:
+				tp.PrintfLine("502 Command not implemented")
+			}
+		}
+	}()
+	return listener.Addr().String(), sessions
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, sessions := fakeSMTPServer(t)
+
+	notifier := NewSMTPNotifier(addr, "Highline <highline@example.com>",
+		[]string{"oncall@example.com", "Ops <ops@example.com>"}, "highline", "hunter2")
+	msg := AlertMessage{
+		Alert:   Alert{Event: AlertServiceDown, Service: "api"},
+		Subject: "[Highline] api is down – again",
+		Text:    "api is down\nNo heartbeat for 2m0s",
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := notifier.Notify(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	var session smtpSession
+	select {
+	case session = <-sessions:
+	case <-ctx.Done():
+		t.Fatal("SMTP conversation did not finish")
+	}
+
+	wantAuth := "PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00highline\x00hunter2"))
+	if session.auth != wantAuth {
+		t.Errorf("AUTH %s, want %s", session.auth, wantAuth)
+	}
+	if session.from != "FROM:<highline@example.com>" {
+		t.Errorf("MAIL %s", session.from)
+	}
+	if !slices.Equal(session.rcpt, []string{"TO:<oncall@example.com>", "TO:<ops@example.com>"}) {
+		t.Errorf("RCPT %v", session.rcpt)
+	}
+	for _, want := range []string{
+		"From: Highline <highline@example.com>\n",
+		"To: oncall@example.com, Ops <ops@example.com>\n",
+		"Subject: =?utf-8?q?[Highline]_api_is_down_=E2=80=93_again?=\n",
+		"X-Highline-Event: service_down\n",
+		"\n\napi is down\nNo heartbeat for 2m0s\n",
+	} {
+		if !strings.Contains(session.data, want) {
+			t.Errorf("message is missing %q:\n%s", want, session.data)
+		}
+	}
+}
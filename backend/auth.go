@@ -0,0 +1,363 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TokenKind distinguishes what an API token may be used for
+type TokenKind string
+
+const (
+	TokenIngest TokenKind = "ingest" // may send heartbeats for one service
+	TokenAdmin  TokenKind = "admin"  // may use every endpoint
+)
+
+// APIToken describes an issued token. The secret itself is never stored.
+type APIToken struct {
+	ID          string     `json:"id"`
+	Kind        TokenKind  `json:"kind"`
+	ServiceName string     `json:"service_name,omitempty"` // ingest tokens only
+	Description string     `json:"description,omitempty"`
+	Prefix      string     `json:"prefix"` // first characters of the token, for identification
+	CreatedAt   time.Time  `json:"created_at"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+}
+
+// storedToken is the persisted form of a token
+type storedToken struct {
+	APIToken
+	Hash string `json:"hash"`
+}
+
+// AllowedRepo is a repository an admin has approved for remediation
+type AllowedRepo struct {
+	Repo    string    `json:"repo"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// AuthStore manages API tokens and the remediation repo allow-list
+type AuthStore struct {
+	mu       sync.RWMutex
+	tokens   map[string]*storedToken // id -> token
+	byHash   map[string]string       // hash -> id
+	repos    map[string]*AllowedRepo // normalized repo URL -> entry
+	adminKey string                  // bootstrap admin key from ADMIN_API_KEY
+	storage  Storage
+}
+
+// NewAuthStore creates an auth store and reloads persisted tokens and repos.
+// Authentication is enabled when adminKey is non-empty.
+func NewAuthStore(adminKey string, storage Storage) *AuthStore {
+	s := &AuthStore{
+		tokens:   make(map[string]*storedToken),
+		byHash:   make(map[string]string),
+		repos:    make(map[string]*AllowedRepo),
+		adminKey: adminKey,
+		storage:  storage,
+	}
+
+	err := storage.Load(bucketTokens, func(key string, data []byte) error {
+		var token storedToken
+		if err := json.Unmarshal(data, &token); err != nil {
+			slog.Warn("Skipping unreadable stored token", "id", key, "error", err)
+			return nil
+		}
+		s.tokens[token.ID] = &token
+		s.byHash[token.Hash] = token.ID
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to load tokens from storage", "error", err)
+	}
+
+	err = storage.Load(bucketAllowedRepos, func(key string, data []byte) error {
+		var repo AllowedRepo
+		if err := json.Unmarshal(data, &repo); err != nil {
+			slog.Warn("Skipping unreadable allowed repo", "repo", key, "error", err)
+			return nil
+		}
+		s.repos[repo.Repo] = &repo
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to load allowed repos from storage", "error", err)
+	}
+
+	slog.Info("Auth store initialized",
+		"auth_enabled", s.Enabled(),
+		"tokens", len(s.tokens),
+		"allowed_repos", len(s.repos),
+	)
+	return s
+}
+
+// Enabled reports whether API authentication is enforced
+func (s *AuthStore) Enabled() bool {
+	return s.adminKey != ""
+}
+
+// hashToken returns the hex SHA-256 of a token secret
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateToken issues a new token and returns its secret. The secret is only
+// available at creation time.
+func (s *AuthStore) CreateToken(kind TokenKind, serviceName, description string) (*APIToken, string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", err
+	}
+	prefix := "hli_"
+	if kind == TokenAdmin {
+		prefix = "hla_"
+	}
+	secret := prefix + hex.EncodeToString(buf)
+
+	token := &storedToken{
+		APIToken: APIToken{
+			ID:          uuid.New().String()[:8],
+			Kind:        kind,
+			ServiceName: serviceName,
+			Description: description,
+			Prefix:      secret[:len(prefix)+6],
+			CreatedAt:   time.Now(),
+		},
+		Hash: hashToken(secret),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.storage.Put(bucketTokens, token.ID, token); err != nil {
+		return nil, "", err
+	}
+	s.tokens[token.ID] = token
+	s.byHash[token.Hash] = token.ID
+
+	info := token.APIToken
+	return &info, secret, nil
+}
+
+// RevokeToken deletes a token. Returns false if it does not exist.
+func (s *AuthStore) RevokeToken(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, exists := s.tokens[id]
+	if !exists {
+		return false
+	}
+	delete(s.tokens, id)
+	delete(s.byHash, token.Hash)
+	if err := s.storage.Delete(bucketTokens, id); err != nil {
+		slog.Error("Failed to delete token from storage", "id", id, "error", err)
+	}
+	return true
+}
+
+// ListTokens returns all issued tokens, oldest first
+func (s *AuthStore) ListTokens() []APIToken {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := make([]APIToken, 0, len(s.tokens))
+	for _, token := range s.tokens {
+		tokens = append(tokens, token.APIToken)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.Before(tokens[j].CreatedAt)
+	})
+	return tokens
+}
+
+// tokenLastUsedResolution is how stale a token's LastUsedAt may get, so a
+// busy token is not written to storage on every request
+const tokenLastUsedResolution = time.Minute
+
+// Authenticate resolves a token secret. Returns false for unknown tokens.
+func (s *AuthStore) Authenticate(secret string) (*APIToken, bool) {
+	if secret == "" {
+		return nil, false
+	}
+
+	if s.adminKey != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(s.adminKey)) == 1 {
+		return &APIToken{ID: "bootstrap", Kind: TokenAdmin}, true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, exists := s.byHash[hashToken(secret)]
+	if !exists {
+		return nil, false
+	}
+	token := s.tokens[id]
+	now := time.Now()
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= tokenLastUsedResolution {
+		token.LastUsedAt = &now
+		if err := s.storage.Put(bucketTokens, token.ID, token); err != nil {
+			slog.Error("Failed to persist token last use", "id", token.ID, "error", err)
+		}
+	}
+
+	info := token.APIToken
+	return &info, true
+}
+
+// normalizeRepoURL makes repo URLs comparable: lowercase host, no trailing
+// slash or .git suffix
+func normalizeRepoURL(repo string) string {
+	repo = strings.TrimSpace(repo)
+	repo = strings.TrimSuffix(repo, "/")
+	repo = strings.TrimSuffix(repo, ".git")
+	if u, err := url.Parse(repo); err == nil && u.Host != "" {
+		u.Host = strings.ToLower(u.Host)
+		u.Scheme = strings.ToLower(u.Scheme)
+		return u.String()
+	}
+	return repo
+}
+
+// AllowRepo adds a repository to the remediation allow-list
+func (s *AuthStore) AllowRepo(repo string) *AllowedRepo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := normalizeRepoURL(repo)
+	if existing, exists := s.repos[key]; exists {
+		return existing
+	}
+
+	entry := &AllowedRepo{Repo: key, AddedAt: time.Now()}
+	s.repos[key] = entry
+	if err := s.storage.Put(bucketAllowedRepos, key, entry); err != nil {
+		slog.Error("Failed to persist allowed repo", "repo", key, "error", err)
+	}
+	return entry
+}
+
+// DisallowRepo removes a repository from the allow-list. Returns false if it was not listed.
+func (s *AuthStore) DisallowRepo(repo string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := normalizeRepoURL(repo)
+	if _, exists := s.repos[key]; !exists {
+		return false
+	}
+	delete(s.repos, key)
+	if err := s.storage.Delete(bucketAllowedRepos, key); err != nil {
+		slog.Error("Failed to delete allowed repo", "repo", key, "error", err)
+	}
+	return true
+}
+
+// IsRepoAllowed reports whether remediation may run against a repository
+func (s *AuthStore) IsRepoAllowed(repo string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, exists := s.repos[normalizeRepoURL(repo)]
+	return exists
+}
+
+// ListAllowedRepos returns the allow-list sorted by repo URL
+func (s *AuthStore) ListAllowedRepos() []AllowedRepo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	repos := make([]AllowedRepo, 0, len(s.repos))
+	for _, repo := range s.repos {
+		repos = append(repos, *repo)
+	}
+	sort.Slice(repos, func(i, j int) bool {
+		return repos[i].Repo < repos[j].Repo
+	})
+	return repos
+}
+
+// bearerToken extracts the token from the Authorization header, falling back to
+// the token query parameter (browsers cannot set headers on WebSocket requests)
+func bearerToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	return r.URL.Query().Get("token")
+}
+
+// requireAdmin rejects requests without a valid admin token when auth is enabled
+func (app *App) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !app.auth.Enabled() {
+			next(w, r)
+			return
+		}
+
+		token, ok := app.auth.Authenticate(bearerToken(r))
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if token.Kind != TokenAdmin {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// refuseWithoutAdminKey rejects a request that would mint credentials, change
+// the allow-list or start an agent while ADMIN_API_KEY is unset: with auth
+// disabled anyone who can reach the API could otherwise do so. Returns true if
+// the request was refused.
+func (app *App) refuseWithoutAdminKey(w http.ResponseWriter) bool {
+	if app.auth.Enabled() {
+		return false
+	}
+	http.Error(w, "ADMIN_API_KEY must be set to use this endpoint", http.StatusForbidden)
+	return true
+}
+
+// requireAdminKey refuses everything but reads on a route while ADMIN_API_KEY
+// is unset
+func (app *App) requireAdminKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && app.refuseWithoutAdminKey(w) {
+			return
+		}
+		next(w, r)
+	}
+}
+
+// authorizeIngest checks that a request may send heartbeats for a service
+func (app *App) authorizeIngest(r *http.Request, serviceName string) (int, bool) {
+	if !app.auth.Enabled() {
+		return 0, true
+	}
+
+	token, ok := app.auth.Authenticate(bearerToken(r))
+	if !ok {
+		return http.StatusUnauthorized, false
+	}
+	if token.Kind == TokenAdmin || (token.Kind == TokenIngest && token.ServiceName == serviceName) {
+		return 0, true
+	}
+	return http.StatusForbidden, false
+}
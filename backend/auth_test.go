@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireAdminKey(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	tests := []struct {
+		adminKey string
+		method   string
+		want     int
+	}{
+		{"", http.MethodGet, http.StatusOK},
+		{"", http.MethodPost, http.StatusForbidden},
+		{"", http.MethodDelete, http.StatusForbidden},
+		{"secret", http.MethodPost, http.StatusOK},
+	}
+	for _, tt := range tests {
+		app := &App{auth: NewAuthStore(tt.adminKey, NewMemoryStorage())}
+		req := httptest.NewRequest(tt.method, "/api/tokens", strings.NewReader(`{"kind": "admin"}`))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+
+		app.requireAdminKey(app.requireAdmin(handler))(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("admin key %q, %s: status %d, want %d", tt.adminKey, tt.method, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestAuthenticatePersistsLastUse(t *testing.T) {
+	storage := NewMemoryStorage()
+	auth := NewAuthStore("secret", storage)
+	token, secret, err := auth.CreateToken(TokenIngest, "api", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := auth.Authenticate(secret); !ok {
+		t.Fatal("new token was not accepted")
+	}
+	reloaded := NewAuthStore("secret", storage).ListTokens()
+	if len(reloaded) != 1 || reloaded[0].ID != token.ID || reloaded[0].LastUsedAt == nil {
+		t.Errorf("reloaded tokens = %+v, want the last use persisted", reloaded)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// BitbucketClient opens and follows pull requests through the Bitbucket Cloud
+// REST API. Tokens are repository, project or workspace access tokens.
+type BitbucketClient struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewBitbucketClient creates a client for the API at baseURL (e.g. https://api.bitbucket.org/2.0)
+func NewBitbucketClient(baseURL, token string) *BitbucketClient {
+	return &BitbucketClient{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// bitbucketBranch is a branch reference in a pull request
+type bitbucketBranch struct {
+	Branch struct {
+		Name string `json:"name"`
+	} `json:"branch"`
+}
+
+// bitbucketPullRequest is the subset of the Bitbucket pull request object Highline uses
+type bitbucketPullRequest struct {
+	ID        int       `json:"id"`
+	State     string    `json:"state"` // OPEN, MERGED, DECLINED or SUPERSEDED
+	UpdatedOn time.Time `json:"updated_on"`
+	Links     struct {
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+	} `json:"links"`
+}
+
+func (b *bitbucketPullRequest) pullRequest() *PullRequest {
+	pr := &PullRequest{
+		Number:  b.ID,
+		HTMLURL: b.Links.HTML.Href,
+		State:   "open",
+	}
+	if b.State != "OPEN" {
+		pr.State = "closed"
+	}
+	if b.State == "MERGED" {
+		// Bitbucket has no merge time; the merge is the last update
+		mergedAt := b.UpdatedOn
+		pr.Merged = true
+		pr.MergedAt = &mergedAt
+	}
+	return pr
+}
+
+// do sends a request and decodes the JSON response into out
+func (c *BitbucketClient) do(ctx context.Context, method, path string, in, out interface{}) error {
+	return doGitAPI(ctx, c.httpClient, ProviderBitbucket, method, c.baseURL+path, in, out, func(req *http.Request) {
+		req.Header.Set("Accept", "application/json")
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+	})
+}
+
+// GetDefaultBranch returns the main branch of a repository
+func (c *BitbucketClient) GetDefaultBranch(ctx context.Context, repo RepoRef) (string, error) {
+	var info struct {
+		MainBranch struct {
+			Name string `json:"name"`
+		} `json:"mainbranch"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/repositories/"+repo.Path, nil, &info); err != nil {
+		return "", err
+	}
+	return info.MainBranch.Name, nil
+}
+
+// CreatePullRequest opens a pull request
+func (c *BitbucketClient) CreatePullRequest(ctx context.Context, repo RepoRef, pr NewPullRequest) (*PullRequest, error) {
+	body := struct {
+		Title             string          `json:"title"`
+		Description       string          `json:"description"`
+		Source            bitbucketBranch `json:"source"`
+		Destination       bitbucketBranch `json:"destination"`
+		CloseSourceBranch bool            `json:"close_source_branch"`
+	}{Title: pr.Title, Description: pr.Body, CloseSourceBranch: true}
+	body.Source.Branch.Name = pr.Head
+	body.Destination.Branch.Name = pr.Base
+
+	var created bitbucketPullRequest
+	if err := c.do(ctx, http.MethodPost, "/repositories/"+repo.Path+"/pullrequests", body, &created); err != nil {
+		return nil, err
+	}
+	return created.pullRequest(), nil
+}
+
+// GetPullRequest fetches a pull request by ID
+func (c *BitbucketClient) GetPullRequest(ctx context.Context, repo RepoRef, number int) (*PullRequest, error) {
+	var pr bitbucketPullRequest
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repositories/%s/pullrequests/%d", repo.Path, number), nil, &pr); err != nil {
+		return nil, err
+	}
+	return pr.pullRequest(), nil
+}
+
+// AddLabels does nothing: Bitbucket pull requests have no labels. Failing
+// tests are still called out in the description.
+func (c *BitbucketClient) AddLabels(ctx context.Context, repo RepoRef, number int, labels []string) error {
+	return nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// contextFileName is where the wrapper script writes the context bundle, in
+// the agent's working directory. It is excluded from the agent's commit.
+const contextFileName = ".highline-context.md"
+
+const (
+	maxContextBytes  = 64 << 10 // rendered bundle; passed to the job in an env var
+	maxContextErrors = 10
+	maxStackFrames   = 30
+
+	// The error log and instructions are cut before they go into the prompt
+	// or the bundle: each env var must stay under the kernel's 128 KiB limit
+	// on a single argument or environment string
+	maxPromptErrorBytes       = 16 << 10
+	maxPromptInstructionBytes = 8 << 10
+)
+
+// truncateUTF8 cuts s to at most n bytes without splitting a rune
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// capText cuts s to at most n bytes, marking that it was cut
+func capText(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return truncateUTF8(s, n) + "\n... (truncated)"
+}
+
+// RemediationDetailResponse is the body of GET /api/remediations/{id}. The
+// context is left out of listings and updates, which it would bloat.
+type RemediationDetailResponse struct {
+	*RemediationRecord
+	Context *RemediationContext `json:"context,omitempty"`
+}
+
+// RemediationContext is the evidence about a failure that the agent gets
+// alongside the error, snapshotted when the remediation is requested
+type RemediationContext struct {
+	Commit      string       `json:"commit,omitempty"`       // deployed commit, if the service reports it
+	StackFrames []StackFrame `json:"stack_frames,omitempty"` // parsed from the error and recent errors
+	Errors      []LogEntry   `json:"errors,omitempty"`       // recent error heartbeats, oldest first
+	Logs        []LogEntry   `json:"logs,omitempty"`         // last log entries, oldest first
+}
+
+// StackFrame is a source location found in a stack trace
+type StackFrame struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Function string `json:"function,omitempty"`
+}
+
+var (
+	// Python: File "app/handlers.py", line 42, in handle
+	pythonFramePattern = regexp.MustCompile(`File "([^"]+)", line (\d+)(?:, in (\S+))?`)
+	// JavaScript: at handle (/app/src/server.js:10:5), Java: at com.acme.Api.handle(Api.java:42)
+	callFramePattern = regexp.MustCompile(`at ([\w.$<>\[\]/-]+) ?\(([^():\s]+):(\d+)(?::\d+)?\)`)
+	// Go, Rust, Ruby, bare JavaScript frames and compiler-style messages: path/file.go:42
+	fileLinePattern = regexp.MustCompile(`([\w./\\@~+-]*\w\.[A-Za-z]{1,6}):(\d+)`)
+	// Go function lines precede their file:line line in goroutine dumps
+	goFuncPattern = regexp.MustCompile(`^([\w./*()-]+\.[\w.*()-]+)\(.*\)$`)
+)
+
+// parseStackFrames extracts the source locations mentioned in text, in order
+// and without duplicates
+func parseStackFrames(text string) []StackFrame {
+	var frames []StackFrame
+	seen := make(map[string]bool)
+	add := func(frame StackFrame) {
+		key := frame.File + ":" + strconv.Itoa(frame.Line)
+		if seen[key] || len(frames) >= maxStackFrames {
+			return
+		}
+		seen[key] = true
+		frames = append(frames, frame)
+	}
+
+	goFunc := ""
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+
+		if m := pythonFramePattern.FindStringSubmatch(line); m != nil {
+			number, _ := strconv.Atoi(m[2])
+			add(StackFrame{File: m[1], Line: number, Function: m[3]})
+			continue
+		}
+		if m := callFramePattern.FindStringSubmatch(line); m != nil {
+			number, _ := strconv.Atoi(m[3])
+			add(StackFrame{File: m[2], Line: number, Function: m[1]})
+			continue
+		}
+		if m := goFuncPattern.FindStringSubmatch(line); m != nil {
+			goFunc = m[1]
+			continue
+		}
+		for _, m := range fileLinePattern.FindAllStringSubmatch(line, -1) {
+			number, _ := strconv.Atoi(m[2])
+			add(StackFrame{File: m[1], Line: number, Function: goFunc})
+			goFunc = ""
+		}
+	}
+	return frames
+}
+
+// buildRemediationContext collects the context for remediating a service:
+// its last maxLogs log entries, its recent errors with their details, the
+// stack frames they mention and the deployed commit
+func buildRemediationContext(service *Service, errorLog string, maxLogs int) *RemediationContext {
+	ctx := &RemediationContext{Commit: service.Commit}
+
+	logs := service.Logs
+	if len(logs) > maxLogs {
+		logs = logs[len(logs)-maxLogs:]
+	}
+	ctx.Logs = append([]LogEntry(nil), logs...)
+
+	for i := len(service.Logs) - 1; i >= 0 && len(ctx.Errors) < maxContextErrors; i-- {
+		if service.Logs[i].Type == "error" {
+			ctx.Errors = append([]LogEntry{service.Logs[i]}, ctx.Errors...)
+		}
+	}
+
+	// Frames of the error being fixed come first
+	var traces strings.Builder
+	traces.WriteString(errorLog)
+	for i := len(ctx.Errors) - 1; i >= 0; i-- {
+		traces.WriteString("\n")
+		traces.WriteString(ctx.Errors[i].Message)
+		for _, value := range ctx.Errors[i].Details {
+			if text, ok := value.(string); ok {
+				traces.WriteString("\n")
+				traces.WriteString(text)
+			}
+		}
+	}
+	ctx.StackFrames = parseStackFrames(traces.String())
+
+	return ctx
+}
+
+// Render formats the context as the markdown file given to the agent. Old
+// log entries are dropped to keep it under maxContextBytes.
+func (c *RemediationContext) Render(serviceName, errorLog string) string {
+	errorLog = capText(errorLog, maxPromptErrorBytes)
+	logs := c.Logs
+	for {
+		rendered := c.render(serviceName, errorLog, logs)
+		if len(rendered) <= maxContextBytes {
+			return rendered
+		}
+		if len(logs) == 0 {
+			return truncateUTF8(rendered, maxContextBytes)
+		}
+		logs = logs[1:]
+	}
+}
+
+func (c *RemediationContext) render(serviceName, errorLog string, logs []LogEntry) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Remediation context for %s\n\n", serviceName)
+	b.WriteString("Collected by Highline when the remediation was requested. Use it to find the cause of the error; do not edit or commit this file.\n\n")
+
+	b.WriteString("## Error\n\n```\n")
+	b.WriteString(errorLog)
+	b.WriteString("\n```\n\n")
+
+	if c.Commit != "" {
+		fmt.Fprintf(&b, "## Deployed commit\n\n%s\n\n", c.Commit)
+	}
+
+	if len(c.StackFrames) > 0 {
+		b.WriteString("## Stack frames\n\n")
+		for _, frame := range c.StackFrames {
+			fmt.Fprintf(&b, "- `%s:%d`", frame.File, frame.Line)
+			if frame.Function != "" {
+				fmt.Fprintf(&b, " in `%s`", frame.Function)
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+
+	if len(c.Errors) > 0 {
+		b.WriteString("## Recent errors\n\n")
+		for _, entry := range c.Errors {
+			writeContextEntry(&b, entry)
+		}
+		b.WriteString("\n")
+	}
+
+	if len(logs) > 0 {
+		b.WriteString("## Recent logs\n\n")
+		for _, entry := range logs {
+			writeContextEntry(&b, entry)
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// writeContextEntry writes a log entry as a list item, with its details as JSON
+func writeContextEntry(b *strings.Builder, entry LogEntry) {
+	fmt.Fprintf(b, "- %s [%s]", entry.Timestamp.UTC().Format("2006-01-02T15:04:05Z"), entry.Type)
+	if entry.EventType != "" {
+		fmt.Fprintf(b, " (%s)", entry.EventType)
+	}
+	b.WriteString(" ")
+	b.WriteString(strings.ReplaceAll(entry.Message, "\n", "\n  "))
+	b.WriteString("\n")
+	if len(entry.Details) > 0 {
+		if details, err := json.Marshal(entry.Details); err == nil {
+			fmt.Fprintf(b, "  details: %s\n", details)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRenderContextStaysValidUTF8(t *testing.T) {
+	// A huge error heartbeat that cannot be dropped to make room, in a
+	// script whose runes take three bytes each
+	ctx := &RemediationContext{
+		Errors: []LogEntry{{Type: "error", Message: "x" + strings.Repeat("错", maxContextBytes/3)}},
+	}
+	rendered := ctx.Render("api", strings.Repeat("误", maxPromptErrorBytes))
+	if len(rendered) > maxContextBytes || !utf8.ValidString(rendered) {
+		t.Errorf("rendered %d bytes, valid UTF-8 %v", len(rendered), utf8.ValidString(rendered))
+	}
+}
+
+func TestBuildAgentPromptCapsInput(t *testing.T) {
+	prompt := buildAgentPrompt(strings.Repeat("é", maxPromptErrorBytes), strings.Repeat("x", 1<<20), "", false)
+	if len(prompt) > maxPromptErrorBytes+maxPromptInstructionBytes+4096 || !utf8.ValidString(prompt) {
+		t.Errorf("prompt is %d bytes, valid UTF-8 %v", len(prompt), utf8.ValidString(prompt))
+	}
+	if strings.Count(prompt, "... (truncated)") != 2 {
+		t.Errorf("prompt does not mark both cuts")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// maxDiffBytes caps the unified diff kept for a remediation. The agent cuts
+// its diff at the same size.
+const maxDiffBytes = 256 << 10
+
+// RemediationDiff is the change an agent committed. The patch is served by
+// /api/remediations/{id}/diff and persisted apart from the record, so record
+// listings stay small.
+type RemediationDiff struct {
+	Files     []string `json:"files"`
+	Bytes     int      `json:"bytes"`     // size of the full diff
+	Truncated bool     `json:"truncated"` // patch was cut at maxDiffBytes
+	Patch     string   `json:"-"`
+}
+
+// RemediationDiffResponse is the body of GET /api/remediations/{id}/diff
+type RemediationDiffResponse struct {
+	ID string `json:"id"`
+	RemediationDiff
+	Patch string `json:"patch"`
+}
+
+// takeReportDiff moves the diff carried by an agent report out of it. Returns
+// nil if the agent committed nothing.
+func takeReportDiff(report *AgentReport) (*RemediationDiff, error) {
+	encoded := report.DiffBase64
+	report.DiffBase64 = ""
+	if encoded == "" && len(report.FilesChanged) == 0 {
+		return nil, nil
+	}
+
+	patch, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid diff_base64: %w", err)
+	}
+
+	diff := &RemediationDiff{
+		Files:     report.FilesChanged,
+		Bytes:     report.DiffBytes,
+		Truncated: report.DiffTruncated,
+		Patch:     string(patch),
+	}
+	if diff.Bytes < len(patch) {
+		diff.Bytes = len(patch)
+	}
+	if len(diff.Patch) > maxDiffBytes {
+		diff.Patch = diff.Patch[:maxDiffBytes]
+		diff.Truncated = true
+	}
+	// Don't end a truncated patch mid-line
+	if diff.Truncated {
+		if i := strings.LastIndexByte(diff.Patch, '\n'); i >= 0 {
+			diff.Patch = diff.Patch[:i+1]
+		}
+	}
+	return diff, nil
+}
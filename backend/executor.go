@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	jobNamePrefix    = "highline-fix-"        // job names are jobNamePrefix + remediation ID
+	remediationLabel = "highline.remediation" // container label holding the remediation ID
+)
+
+// ExecutorJob describes one agent run
+type ExecutorJob struct {
+	RemediationID string
+	Name          string            // unique job name, e.g. highline-fix-<id>
+	Script        string            // /bin/sh script to run
+	Env           []string          // KEY=VALUE pairs
+	Output        func(line string) // called with each line the job prints; may be nil
+}
+
+// ExecutorResult is the outcome of a finished job
+type ExecutorResult struct {
+	ExitCode int64
+}
+
+// RemediationExecutor runs agent jobs. Implementations exist for Docker and
+// local processes; a Kubernetes Job executor would implement the same
+// interface, mapping Name to the Job name and Env to the pod spec.
+type RemediationExecutor interface {
+	// Kind identifies the executor, e.g. "docker"
+	Kind() string
+	// Available returns an error if the executor cannot run jobs
+	Available() error
+	// DefaultBackendURL is how jobs reach the backend when BACKEND_URL is unset
+	DefaultBackendURL() string
+	// Run starts the job and blocks until it exits. started is called with the
+	// job's handle (container ID, PID, ...) once it is running. Cancelling ctx
+	// stops the job and Run returns ctx.Err().
+	Run(ctx context.Context, job ExecutorJob, started func(handle string)) (ExecutorResult, error)
+}
+
+// ExecutorJobInfo describes a job found on an executor
+type ExecutorJobInfo struct {
+	Handle        string
+	Name          string
+	RemediationID string
+	Running       bool
+	FinishedAt    time.Time // zero while running or if unknown
+}
+
+// ReapingExecutor is implemented by executors whose jobs outlive the backend:
+// they keep running across a restart and leave resources behind when they
+// finish (stopped containers). The local executor cleans up after itself.
+type ReapingExecutor interface {
+	RemediationExecutor
+	// ListJobs returns the executor's highline-fix jobs, running or finished
+	ListJobs(ctx context.Context) ([]ExecutorJobInfo, error)
+	// Attach follows a job started before a restart like Run does
+	Attach(ctx context.Context, job ExecutorJob, handle string) (ExecutorResult, error)
+	// Remove deletes a job, stopping it if it is still running
+	Remove(ctx context.Context, job ExecutorJobInfo) error
+}
+
+// NewExecutorFromEnv creates the executor selected by REMEDIATION_EXECUTOR
+// (docker by default)
+func NewExecutorFromEnv() (RemediationExecutor, error) {
+	kind := os.Getenv("REMEDIATION_EXECUTOR")
+	switch kind {
+	case "", "docker":
+		image := os.Getenv("OPENCODE_IMAGE")
+		if image == "" {
+			image = "ghcr.io/anomalyco/opencode:latest"
+		}
+		return NewDockerExecutor(image), nil
+	case "local":
+		return NewLocalExecutor(os.Getenv("LOCAL_EXECUTOR_DIR")), nil
+	default:
+		return nil, fmt.Errorf("unknown REMEDIATION_EXECUTOR %q (expected docker or local)", kind)
+	}
+}
+
+// logAgentOutput logs each non-empty line the agent prints and passes it to
+// the job's Output
+func logAgentOutput(job ExecutorJob, reader io.Reader) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) != "" {
+			slog.Info("[REMEDIATION][LOG]",
+				"id", job.RemediationID,
+				"content", line,
+			)
+			if job.Output != nil {
+				job.Output(line)
+			}
+		}
+	}
+}
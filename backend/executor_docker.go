@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// DockerExecutor runs agent jobs as containers via the Docker Engine API
+type DockerExecutor struct {
+	client *client.Client // nil when Docker is unavailable
+	image  string
+	err    error
+}
+
+// NewDockerExecutor connects to Docker using the standard environment
+func NewDockerExecutor(image string) *DockerExecutor {
+	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		slog.Warn("Failed to create Docker client - remediation disabled", "error", err)
+		return &DockerExecutor{image: image, err: err}
+	}
+	return &DockerExecutor{client: dockerClient, image: image}
+}
+
+func (e *DockerExecutor) Kind() string { return "docker" }
+
+func (e *DockerExecutor) Available() error {
+	if e.client == nil {
+		return fmt.Errorf("docker client not available: %v", e.err)
+	}
+	return nil
+}
+
+func (e *DockerExecutor) DefaultBackendURL() string {
+	return "http://host.docker.internal:8080"
+}
+
+// Run pulls the image, creates and starts the container and waits for it
+func (e *DockerExecutor) Run(ctx context.Context, job ExecutorJob, started func(handle string)) (ExecutorResult, error) {
+	if err := e.Available(); err != nil {
+		return ExecutorResult{}, err
+	}
+
+	// Pull the OpenCode image
+	slog.Info("[REMEDIATION] Pulling OpenCode image",
+		"id", job.RemediationID,
+		"image", e.image,
+	)
+
+	reader, err := e.client.ImagePull(ctx, e.image, image.PullOptions{})
+	if err != nil {
+		slog.Warn("[REMEDIATION] Failed to pull image, using local",
+			"id", job.RemediationID,
+			"error", err,
+		)
+	} else {
+		io.Copy(io.Discard, reader)
+		reader.Close()
+	}
+
+	containerConfig := &container.Config{
+		Image:      e.image,
+		Env:        append([]string{"TERM=dumb"}, job.Env...),
+		Entrypoint: []string{"/bin/sh", "-c"},
+		Cmd:        []string{job.Script},
+		WorkingDir: "/workspace",
+		Tty:        false,
+		Labels:     map[string]string{remediationLabel: job.RemediationID},
+	}
+
+	hostConfig := &container.HostConfig{
+		AutoRemove: false,
+		Resources: container.Resources{
+			Memory:   2 * 1024 * 1024 * 1024,
+			NanoCPUs: 2 * 1e9,
+		},
+		// Allow container to reach host network for callback
+		ExtraHosts: []string{"host.docker.internal:host-gateway"},
+	}
+
+	// Create the container
+	slog.Info("[REMEDIATION] Creating container",
+		"id", job.RemediationID,
+		"container_name", job.Name,
+	)
+
+	resp, err := e.client.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, job.Name)
+	if err != nil {
+		return ExecutorResult{}, fmt.Errorf("failed to create container: %w", err)
+	}
+
+	slog.Info("[REMEDIATION] Container created",
+		"id", job.RemediationID,
+		"container_id", resp.ID[:12],
+	)
+
+	// Start the container
+	if err := e.client.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		// ctx may already be cancelled
+		e.cleanupContainer(context.Background(), resp.ID, job.RemediationID)
+		return ExecutorResult{}, fmt.Errorf("failed to start container: %w", err)
+	}
+	started(resp.ID)
+
+	slog.Info("[REMEDIATION] Container started - OpenCode agent is working",
+		"id", job.RemediationID,
+		"container_id", resp.ID[:12],
+	)
+
+	return e.follow(ctx, resp.ID, job)
+}
+
+// Attach follows a container started before a backend restart until it exits.
+// Its output is replayed from the start.
+func (e *DockerExecutor) Attach(ctx context.Context, job ExecutorJob, handle string) (ExecutorResult, error) {
+	if err := e.Available(); err != nil {
+		return ExecutorResult{}, err
+	}
+	return e.follow(ctx, handle, job)
+}
+
+// follow streams a running container's logs and waits for it to exit,
+// stopping it if ctx is cancelled
+func (e *DockerExecutor) follow(ctx context.Context, containerID string, job ExecutorJob) (ExecutorResult, error) {
+	// Stream logs in background
+	go e.streamContainerLogs(ctx, containerID, job)
+
+	// Wait for completion
+	statusCh, errCh := e.client.ContainerWait(ctx, containerID, container.WaitConditionNotRunning)
+
+	select {
+	case err := <-errCh:
+		if ctx.Err() != nil {
+			e.stopContainer(containerID, job.RemediationID)
+			return ExecutorResult{}, ctx.Err()
+		}
+		return ExecutorResult{}, fmt.Errorf("error waiting for container: %w", err)
+
+	case status := <-statusCh:
+		return ExecutorResult{ExitCode: status.StatusCode}, nil
+
+	case <-ctx.Done():
+		e.stopContainer(containerID, job.RemediationID)
+		return ExecutorResult{}, ctx.Err()
+	}
+}
+
+// ListJobs returns the highline-fix containers, running or stopped
+func (e *DockerExecutor) ListJobs(ctx context.Context) ([]ExecutorJobInfo, error) {
+	if err := e.Available(); err != nil {
+		return nil, err
+	}
+
+	containers, err := e.client.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("name", jobNamePrefix)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	jobs := make([]ExecutorJobInfo, 0, len(containers))
+	for _, c := range containers {
+		var name string
+		for _, n := range c.Names {
+			if n = strings.TrimPrefix(n, "/"); strings.HasPrefix(n, jobNamePrefix) {
+				name = n
+			}
+		}
+		// The name filter matches substrings
+		if name == "" {
+			continue
+		}
+
+		job := ExecutorJobInfo{
+			Handle:        c.ID,
+			Name:          name,
+			RemediationID: c.Labels[remediationLabel],
+			Running:       c.State == "running",
+		}
+		// Containers created before labels were added
+		if job.RemediationID == "" {
+			job.RemediationID = strings.TrimPrefix(name, jobNamePrefix)
+		}
+		if !job.Running {
+			if info, err := e.client.ContainerInspect(ctx, c.ID); err == nil && info.State != nil {
+				job.FinishedAt, _ = time.Parse(time.RFC3339Nano, info.State.FinishedAt)
+			}
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// Remove deletes a container, stopping it first if it is running
+func (e *DockerExecutor) Remove(ctx context.Context, job ExecutorJobInfo) error {
+	if err := e.Available(); err != nil {
+		return err
+	}
+	return e.cleanupContainer(ctx, job.Handle, job.RemediationID)
+}
+
+// stopContainer stops a container whose job was cancelled or timed out
+func (e *DockerExecutor) stopContainer(containerID, remediationID string) {
+	slog.Info("[REMEDIATION] Stopping container",
+		"id", remediationID,
+		"container_id", containerID[:12],
+	)
+	stopTimeout := 10
+	e.client.ContainerStop(context.Background(), containerID, container.StopOptions{Timeout: &stopTimeout})
+}
+
+// cleanupContainer removes the container
+func (e *DockerExecutor) cleanupContainer(ctx context.Context, containerID, remediationID string) error {
+	slog.Info("[REMEDIATION] Cleaning up container",
+		"id", remediationID,
+		"container_id", containerID[:12],
+	)
+
+	err := e.client.ContainerRemove(ctx, containerID, container.RemoveOptions{
+		Force:         true,
+		RemoveVolumes: true,
+	})
+
+	if err != nil {
+		slog.Warn("[REMEDIATION] Failed to remove container",
+			"id", remediationID,
+			"error", err,
+		)
+	} else {
+		slog.Info("[REMEDIATION] Container removed",
+			"id", remediationID,
+		)
+	}
+	return err
+}
+
+// streamContainerLogs streams container logs properly handling the Docker multiplexed stream
+func (e *DockerExecutor) streamContainerLogs(ctx context.Context, containerID string, job ExecutorJob) {
+	reader, err := e.client.ContainerLogs(ctx, containerID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+		Timestamps: false,
+	})
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+
+	// Use a pipe to convert the multiplexed stream into a readable format
+	stdoutReader, stdoutWriter := io.Pipe()
+
+	go func() {
+		// StdCopy handles the 8-byte Docker headers correctly
+		// It will split the multiplexed stream from reader into stdoutWriter and stderrWriter (we use same for both)
+		_, err := stdcopy.StdCopy(stdoutWriter, stdoutWriter, reader)
+		if err != nil {
+			slog.Error("[REMEDIATION][LOG_ERROR]", "id", job.RemediationID, "error", err)
+		}
+		stdoutWriter.Close()
+	}()
+
+	logAgentOutput(job, stdoutReader)
+}
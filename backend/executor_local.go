@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// LocalExecutor runs agent jobs as child processes in a temporary directory.
+// The agent CLI (and git) must be installed on the host. Useful for
+// development and for testing the remediation flow without Docker.
+type LocalExecutor struct {
+	baseDir string // parent of per-job workspaces; os.TempDir() when empty
+}
+
+// NewLocalExecutor creates a local process executor
+func NewLocalExecutor(baseDir string) *LocalExecutor {
+	return &LocalExecutor{baseDir: baseDir}
+}
+
+func (e *LocalExecutor) Kind() string { return "local" }
+
+func (e *LocalExecutor) Available() error {
+	if _, err := exec.LookPath("sh"); err != nil {
+		return fmt.Errorf("local executor needs /bin/sh: %w", err)
+	}
+	return nil
+}
+
+func (e *LocalExecutor) DefaultBackendURL() string {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	return "http://localhost:" + port
+}
+
+// Run executes the script with sh in a fresh workspace. Only PATH and the job
+// env are passed through, so host secrets are not leaked to the agent.
+func (e *LocalExecutor) Run(ctx context.Context, job ExecutorJob, started func(handle string)) (ExecutorResult, error) {
+	dir, err := os.MkdirTemp(e.baseDir, job.Name+"-")
+	if err != nil {
+		return ExecutorResult{}, fmt.Errorf("failed to create workspace: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cmd := exec.Command("/bin/sh", "-c", job.Script)
+	cmd.Dir = dir
+	cmd.Env = append([]string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + dir,
+		"WORKSPACE=" + dir + "/workspace",
+		"TERM=dumb",
+	}, job.Env...)
+	// Own process group so cancellation also stops the agent's children
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	output, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+
+	if err := cmd.Start(); err != nil {
+		return ExecutorResult{}, fmt.Errorf("failed to start agent: %w", err)
+	}
+	started(strconv.Itoa(cmd.Process.Pid))
+
+	slog.Info("[REMEDIATION] Local agent started",
+		"id", job.RemediationID,
+		"pid", cmd.Process.Pid,
+		"workspace", dir,
+	)
+
+	logsDone := make(chan struct{})
+	go func() {
+		logAgentOutput(job, output)
+		close(logsDone)
+	}()
+
+	waitCh := make(chan error, 1)
+	go func() {
+		waitCh <- cmd.Wait()
+		writer.Close()
+	}()
+
+	select {
+	case err := <-waitCh:
+		<-logsDone
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return ExecutorResult{ExitCode: int64(exitErr.ExitCode())}, nil
+		}
+		if err != nil {
+			return ExecutorResult{}, fmt.Errorf("error waiting for agent: %w", err)
+		}
+		return ExecutorResult{}, nil
+
+	case <-ctx.Done():
+		slog.Info("[REMEDIATION] Stopping local agent",
+			"id", job.RemediationID,
+			"pid", cmd.Process.Pid,
+		)
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+		select {
+		case <-waitCh:
+		case <-time.After(10 * time.Second):
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+			<-waitCh
+		}
+		return ExecutorResult{}, ctx.Err()
+	}
+}
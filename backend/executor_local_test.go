@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeAgentCommand stands in for a coding agent: it checks its config file
+// and prompt, asks the model for a line of code and appends it to main.go
+const fakeAgentCommand = `test -f "$HOME/.config/fake/config.json" || exit 3
+grep -q "main.main undefined" "$AGENT_PROMPT_FILE" || exit 2
+REPLY=$(wget -qO- --header="Authorization: Bearer $AGENT_API_KEY" --header='Content-Type: application/json' \
+    --post-data='{"model": "'"$AGENT_MODEL"'", "messages": [{"role": "user", "content": "fix it"}]}' \
+    "$AGENT_BASE_URL/chat/completions") || exit 4
+printf '%s\n' "$REPLY" | sed -n 's/.*"content": *"\([^"]*\)".*/\1/p' >> main.go`
+
+// fakeLLM is an OpenAI-compatible chat completions endpoint that always
+// answers with the same line of code
+func fakeLLM(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer test-key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			Model string `json:"model"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Model != "fake-model" {
+			http.Error(w, "unknown model", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]string{"role": "assistant", "content": "func main() {}"}},
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newLocalRemediationApp wires a remediation service with the local executor
+// to a backend that receives the agent's callbacks
+func newLocalRemediationApp(t *testing.T, command string) *App {
+	t.Helper()
+	for _, tool := range []string{"git", "wget", "openssl"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not installed", tool)
+		}
+	}
+
+	app := &App{
+		store:            NewServiceStore(time.Minute, true, NewMemoryStorage()),
+		remediationStore: NewRemediationStore(NewMemoryStorage()),
+		remediationLogs:  NewRemediationLogs(1000),
+		gitHosts:         &GitHosts{hosts: make(map[string]GitHost)},
+		wsHub:            NewWSHub(),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/remediation/report", app.RemediationReportHandler)
+	mux.HandleFunc("/api/remediation/progress", app.RemediationProgressHandler)
+	backend := httptest.NewServer(mux)
+	t.Cleanup(backend.Close)
+
+	t.Setenv("TEST_AGENT_KEY", "test-key")
+	t.Setenv("BACKEND_URL", backend.URL)
+	agents := &AgentRegistry{
+		defaultName: "fake",
+		providers: map[string]AgentProvider{"fake": {
+			Name:           "fake",
+			Command:        command,
+			ConfigPath:     ".config/fake/config.json",
+			ConfigTemplate: `{"model": {{json .Model}}, "endpoint": {{json .BaseURL}}}`,
+			Model:          "fake-model",
+			BaseURL:        fakeLLM(t).URL + "/v1",
+			APIKeyEnv:      "TEST_AGENT_KEY",
+		}},
+	}
+	app.remediation = NewRemediationService(app.remediationStore, app.remediationLogs,
+		NewLocalExecutor(t.TempDir()), agents, app.gitHosts)
+	return app
+}
+
+// startRemediation queues a remediation for the repo and claims it like a
+// worker would
+func startRemediation(t *testing.T, app *App, repoURL string, mode RemediationMode) *RemediationRecord {
+	t.Helper()
+	app.remediationStore.Create(RemediationRequest{
+		ID:          newRemediationID(),
+		ServiceName: "api",
+		GitHubRepo:  repoURL,
+		ErrorLog:    "main.main undefined",
+		Mode:        mode,
+	})
+	record, ok := app.remediationStore.ClaimNext()
+	if !ok {
+		t.Fatal("no remediation queued")
+	}
+	return record
+}
+
+func TestLocalExecutorRemediation(t *testing.T) {
+	tests := []struct {
+		mode    RemediationMode
+		pushed  bool
+		summary string
+		phases  []AgentPhase
+	}{
+		{RemediationModeAuto, true, "Successfully applied and pushed fix",
+			[]AgentPhase{PhaseCloned, PhaseAnalyzing, PhaseEdited, PhaseCommitted, PhasePushed}},
+		{RemediationModeSuggest, false, "Fix suggested on branch",
+			[]AgentPhase{PhaseCloned, PhaseAnalyzing, PhaseEdited, PhaseCommitted}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			bare := newBareRepo(t)
+			app := newLocalRemediationApp(t, fakeAgentCommand)
+			record := startRemediation(t, app, "file://"+bare, tt.mode)
+
+			if err := app.remediation.RunAgent(record); err != nil {
+				t.Fatalf("RunAgent: %v\n%s", err, agentOutput(app, record.ID))
+			}
+
+			done, _ := app.remediationStore.Get(record.ID)
+			report := done.AgentReport
+			if done.Status != RemediationSuccess || report == nil || !report.Success {
+				t.Fatalf("status %s, report %+v\n%s", done.Status, report,
+					agentOutput(app, record.ID))
+			}
+			if report.Pushed != tt.pushed || !strings.HasPrefix(report.Summary, tt.summary) {
+				t.Errorf("pushed = %v, summary %q", report.Pushed, report.Summary)
+			}
+			if !slices.Equal(report.FilesChanged, []string{"main.go"}) || report.CommitHash == "" {
+				t.Errorf("files %v, commit %q", report.FilesChanged, report.CommitHash)
+			}
+			if done.AgentProvider != "fake" || done.AgentModel != "fake-model" {
+				t.Errorf("agent = %s/%s", done.AgentProvider, done.AgentModel)
+			}
+			if done.Diff == nil || !strings.Contains(done.Diff.Patch, "+func main() {}") {
+				t.Errorf("diff = %+v", done.Diff)
+			}
+
+			var phases []AgentPhase
+			for _, event := range done.Phases {
+				phases = append(phases, event.Phase)
+			}
+			if !slices.Equal(phases, tt.phases) {
+				t.Errorf("phases = %v, want %v", phases, tt.phases)
+			}
+
+			branch := fixBranchName(record.ID)
+			if !tt.pushed {
+				if out, err := exec.Command("git", "--git-dir", bare, "rev-parse", "--verify", "--quiet", branch).Output(); err == nil {
+					t.Errorf("suggest mode pushed %s at %s", branch, out)
+				}
+				return
+			}
+			if pushed := runGit(t, bare, "rev-parse", branch); pushed != report.CommitHash {
+				t.Errorf("%s is at %s, want %s", branch, pushed, report.CommitHash)
+			}
+			if content := runGit(t, bare, "show", branch+":main.go"); content != "package main\nfunc main() {}" {
+				t.Errorf("pushed main.go = %q", content)
+			}
+		})
+	}
+}
+
+func TestLocalExecutorKeepsReportSecretOutOfOutput(t *testing.T) {
+	bare := newBareRepo(t)
+	app := newLocalRemediationApp(t, fakeAgentCommand)
+	record := startRemediation(t, app, "file://"+bare, RemediationModeAuto)
+
+	if err := app.remediation.RunAgent(record); err != nil {
+		t.Fatalf("RunAgent: %v\n%s", err, agentOutput(app, record.ID))
+	}
+	done, _ := app.remediationStore.Get(record.ID)
+	if done.AgentReport == nil || len(done.Phases) == 0 {
+		t.Fatalf("signed callbacks were not accepted\n%s", agentOutput(app, record.ID))
+	}
+	ipad, opad := hmacKeyPads(done.ReportSecret)
+	output := agentOutput(app, record.ID)
+	for _, secret := range []string{done.ReportSecret, ipad, opad} {
+		if done.ReportSecret == "" || strings.Contains(output, secret) {
+			t.Fatalf("job output contains the report secret %q:\n%s", secret, output)
+		}
+	}
+}
+
+func TestLocalExecutorLargeDiff(t *testing.T) {
+	bare := newBareRepo(t)
+	// Well past the 128 KiB a single argument may take, still under maxDiffBytes
+	app := newLocalRemediationApp(t, `awk 'BEGIN { for (i = 0; i < 3000; i++) printf "// line %05d of a fix that is far too long\n", i }' >> main.go`)
+	record := startRemediation(t, app, "file://"+bare, RemediationModeAuto)
+
+	if err := app.remediation.RunAgent(record); err != nil {
+		t.Fatalf("RunAgent: %v\n%s", err, agentOutput(app, record.ID))
+	}
+	done, _ := app.remediationStore.Get(record.ID)
+	if done.Status != RemediationSuccess || done.AgentReport == nil || !done.AgentReport.Success {
+		t.Fatalf("status %s, report %+v", done.Status, done.AgentReport)
+	}
+	if done.Diff == nil || done.Diff.Bytes < 128<<10 || done.Diff.Truncated || len(done.Diff.Patch) != done.Diff.Bytes {
+		t.Errorf("diff = %+v", done.Diff)
+	}
+}
+
+func TestLocalExecutorAgentFailure(t *testing.T) {
+	bare := newBareRepo(t)
+	// The key the agent is given is not the one the model accepts
+	app := newLocalRemediationApp(t, fakeAgentCommand)
+	t.Setenv("TEST_AGENT_KEY", "wrong-key")
+	record := startRemediation(t, app, "file://"+bare, RemediationModeAuto)
+
+	if err := app.remediation.RunAgent(record); err == nil {
+		t.Fatal("RunAgent succeeded with an agent that exits 4")
+	}
+	done, _ := app.remediationStore.Get(record.ID)
+	if done.Status != RemediationFailed || done.ExitCode == nil || *done.ExitCode != 4 {
+		t.Errorf("status %s, exit code %v", done.Status, done.ExitCode)
+	}
+	if report := done.AgentReport; report == nil || report.Success || report.Pushed ||
+		report.Summary != "Failed to apply or push fix (exit: 4)" {
+		t.Errorf("report = %+v", done.AgentReport)
+	}
+}
+
+func TestLocalExecutorTestsBlockFix(t *testing.T) {
+	bare := newBareRepo(t)
+	app := newLocalRemediationApp(t, fakeAgentCommand)
+	app.remediationStore.Create(RemediationRequest{
+		ID:          newRemediationID(),
+		ServiceName: "api",
+		GitHubRepo:  "file://" + bare,
+		ErrorLog:    "main.main undefined",
+		Mode:        RemediationModeAuto,
+		TestPolicy:  &TestPolicy{Command: "echo FAIL: TestMain; exit 3"},
+	})
+	record, _ := app.remediationStore.ClaimNext()
+
+	if err := app.remediation.RunAgent(record); err == nil {
+		t.Fatal("RunAgent succeeded with failing tests")
+	}
+	done, _ := app.remediationStore.Get(record.ID)
+	if done.Status != RemediationFailed || done.ExitCode == nil || *done.ExitCode != 3 {
+		t.Errorf("status %s, exit code %v", done.Status, done.ExitCode)
+	}
+	report := done.AgentReport
+	if report == nil || report.Success || report.Pushed || report.Tests == nil || report.Tests.Result != TestsFailed {
+		t.Fatalf("report = %+v", report)
+	}
+	if !strings.HasPrefix(done.ErrorMessage, "Tests failed on branch") {
+		t.Errorf("error message = %q", done.ErrorMessage)
+	}
+	branch := fixBranchName(record.ID)
+	if out, err := exec.Command("git", "--git-dir", bare, "rev-parse", "--verify", "--quiet", branch).Output(); err == nil {
+		t.Errorf("blocked fix was pushed to %s at %s", branch, out)
+	}
+}
+
+func TestLocalExecutorNoChanges(t *testing.T) {
+	bare := newBareRepo(t)
+	// The agent exits cleanly without touching the repo
+	app := newLocalRemediationApp(t, "true")
+	record := startRemediation(t, app, "file://"+bare, RemediationModeAuto)
+
+	if err := app.remediation.RunAgent(record); err == nil {
+		t.Fatal("RunAgent succeeded without a fix")
+	}
+	done, _ := app.remediationStore.Get(record.ID)
+	if done.Status != RemediationFailed || done.ExitCode == nil || *done.ExitCode == 0 {
+		t.Errorf("status %s, exit code %v", done.Status, done.ExitCode)
+	}
+	if done.ErrorMessage != "Failed to apply or push fix (exit: 0)" {
+		t.Errorf("error message = %q", done.ErrorMessage)
+	}
+}
+
+func TestLocalExecutorCancel(t *testing.T) {
+	bare := newBareRepo(t)
+	app := newLocalRemediationApp(t, "sleep 60")
+	record := startRemediation(t, app, "file://"+bare, RemediationModeAuto)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- app.remediation.RunAgent(record) }()
+
+	deadline := time.Now().Add(30 * time.Second)
+	for !agentStarted(app, record.ID) {
+		if time.Now().After(deadline) {
+			t.Fatal("agent did not start")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if !app.remediation.Cancel(record.ID) {
+		t.Fatal("running remediation could not be cancelled")
+	}
+
+	select {
+	case err := <-errCh:
+		if err != ErrRemediationCancelled {
+			t.Errorf("RunAgent = %v, want ErrRemediationCancelled", err)
+		}
+	case <-time.After(20 * time.Second):
+		t.Fatal("cancelled agent kept running")
+	}
+	if done, _ := app.remediationStore.Get(record.ID); done.Status != RemediationCancelled {
+		t.Errorf("status = %s, want cancelled", done.Status)
+	}
+}
+
+// agentOutput returns what a remediation's job printed so far
+func agentOutput(app *App, id string) string {
+	var b strings.Builder
+	for _, line := range app.remediationLogs.Since(id, 0).Lines {
+		b.WriteString(line.Text + "\n")
+	}
+	return b.String()
+}
+
+// agentStarted reports whether the wrapper has handed over to the agent
+func agentStarted(app *App, id string) bool {
+	return strings.Contains(agentOutput(app, id), "RUNNING AGENT")
+}
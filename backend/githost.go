@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GitProviderKind is the kind of server hosting a repository
+type GitProviderKind string
+
+const (
+	ProviderGitHub    GitProviderKind = "github" // github.com or GitHub Enterprise
+	ProviderGitLab    GitProviderKind = "gitlab"
+	ProviderBitbucket GitProviderKind = "bitbucket" // Bitbucket Cloud
+	ProviderGitea     GitProviderKind = "gitea"     // Gitea and Forgejo
+	ProviderGit       GitProviderKind = "git"       // plain git remote: fix branches are pushed, no pull requests
+)
+
+// GitHost holds the credentials and API settings for one git host. Secrets
+// are named by server env vars, like agent API keys.
+type GitHost struct {
+	Host          string          `json:"host"`                      // host[:port] of the repo URLs, e.g. gitlab.example.com
+	Provider      GitProviderKind `json:"provider"`                  // github, gitlab, bitbucket, gitea or git
+	APIURL        string          `json:"api_url,omitempty"`         // defaults per provider
+	TokenEnv      string          `json:"token_env,omitempty"`       // server env var holding an HTTPS/API token
+	Username      string          `json:"username,omitempty"`        // HTTPS user for the token; defaults per provider
+	SSHKeyEnv     string          `json:"ssh_key_env,omitempty"`     // server env var holding a deploy key; clones over SSH
+	SSHPort       int             `json:"ssh_port,omitempty"`        // default 22
+	KnownHostsEnv string          `json:"known_hosts_env,omitempty"` // server env var with known_hosts lines; required with ssh_key_env
+}
+
+// Validate checks that the host can be used
+func (h *GitHost) Validate() error {
+	if h.Host == "" {
+		return fmt.Errorf("git host is required")
+	}
+	switch h.Provider {
+	case ProviderGitHub, ProviderGitLab, ProviderBitbucket, ProviderGitea, ProviderGit:
+	default:
+		return fmt.Errorf("git host %q: unknown provider %q", h.Host, h.Provider)
+	}
+	if h.Provider != ProviderGit && h.TokenEnv == "" {
+		return fmt.Errorf("git host %q: token_env is required to open %s pull requests", h.Host, h.Provider)
+	}
+	if h.SSHPort < 0 || h.SSHPort > 65535 {
+		return fmt.Errorf("git host %q: invalid ssh_port", h.Host)
+	}
+	// A fresh job has no known hosts, so an unpinned host key is never checked
+	if (h.KnownHostsEnv == "") != (h.SSHKeyEnv == "") {
+		return fmt.Errorf("git host %q: ssh_key_env and known_hosts_env must be set together", h.Host)
+	}
+	return nil
+}
+
+// apiURL returns the REST API base URL of the host
+func (h *GitHost) apiURL() string {
+	if h.APIURL != "" {
+		return strings.TrimSuffix(h.APIURL, "/")
+	}
+	switch h.Provider {
+	case ProviderGitHub:
+		if h.Host == "github.com" {
+			return "https://api.github.com"
+		}
+		return "https://" + h.Host + "/api/v3"
+	case ProviderGitLab:
+		return "https://" + h.Host + "/api/v4"
+	case ProviderBitbucket:
+		return "https://api.bitbucket.org/2.0"
+	case ProviderGitea:
+		return "https://" + h.Host + "/api/v1"
+	}
+	return ""
+}
+
+// username returns the HTTPS user the host expects with a token
+func (h *GitHost) username() string {
+	if h.Username != "" {
+		return h.Username
+	}
+	switch h.Provider {
+	case ProviderGitHub:
+		return "x-access-token"
+	case ProviderGitLab:
+		return "oauth2"
+	case ProviderBitbucket:
+		return "x-token-auth"
+	}
+	return "git"
+}
+
+// token returns the host's token from the server environment
+func (h *GitHost) token() (string, error) {
+	if h.TokenEnv == "" {
+		return "", nil
+	}
+	token := os.Getenv(h.TokenEnv)
+	if token == "" {
+		return "", fmt.Errorf("%s not set", h.TokenEnv)
+	}
+	return token, nil
+}
+
+// RepoRef identifies a repository by its URL
+type RepoRef struct {
+	URL    string
+	Scheme string // https or file
+	Host   string // lowercase host[:port]; empty for file URLs
+	Path   string // owner/repo, or group/subgroup/repo on GitLab, without .git
+}
+
+// Owner returns everything before the repository name
+func (r RepoRef) Owner() string {
+	if i := strings.LastIndexByte(r.Path, '/'); i >= 0 {
+		return r.Path[:i]
+	}
+	return ""
+}
+
+// Name returns the repository name
+func (r RepoRef) Name() string {
+	return r.Path[strings.LastIndexByte(r.Path, '/')+1:]
+}
+
+// parseRepoURL splits a repository URL such as https://host/owner/repo(.git)
+func parseRepoURL(repoURL string) (RepoRef, error) {
+	repoURL = strings.TrimSpace(repoURL)
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return RepoRef{}, fmt.Errorf("invalid repo URL: %w", err)
+	}
+
+	path := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	ref := RepoRef{
+		URL:    repoURL,
+		Scheme: strings.ToLower(u.Scheme),
+		Host:   strings.ToLower(u.Host),
+		Path:   path,
+	}
+	switch {
+	case ref.Scheme == "file" && ref.Host == "" && path != "":
+	case ref.Scheme == "https" && ref.Host != "" && strings.Count(path, "/") >= 1 && !strings.Contains(path, "//"):
+	default:
+		return RepoRef{}, fmt.Errorf("repo URL must look like https://host/owner/repo: %s", repoURL)
+	}
+	return ref, nil
+}
+
+// GitProvider opens and follows pull requests (merge requests on GitLab) on
+// a git host
+type GitProvider interface {
+	GetDefaultBranch(ctx context.Context, repo RepoRef) (string, error)
+	CreatePullRequest(ctx context.Context, repo RepoRef, pr NewPullRequest) (*PullRequest, error)
+	GetPullRequest(ctx context.Context, repo RepoRef, number int) (*PullRequest, error)
+	AddLabels(ctx context.Context, repo RepoRef, number int, labels []string) error
+}
+
+// GitHosts holds the configured git hosts
+type GitHosts struct {
+	hosts map[string]GitHost // by lowercase host[:port]
+}
+
+// NewGitHostsFromEnv loads hosts from GIT_HOSTS_FILE (a JSON array). GITHUB_PAT
+// (and GITHUB_API_URL) configure github.com unless the file does.
+func NewGitHostsFromEnv() (*GitHosts, error) {
+	g := &GitHosts{hosts: make(map[string]GitHost)}
+
+	if path := os.Getenv("GIT_HOSTS_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read GIT_HOSTS_FILE: %w", err)
+		}
+		var hosts []GitHost
+		if err := json.Unmarshal(data, &hosts); err != nil {
+			return nil, fmt.Errorf("invalid GIT_HOSTS_FILE: %w", err)
+		}
+		for _, host := range hosts {
+			host.Host = strings.ToLower(host.Host)
+			if err := host.Validate(); err != nil {
+				return nil, err
+			}
+			g.hosts[host.Host] = host
+		}
+	}
+
+	if _, exists := g.hosts["github.com"]; !exists && os.Getenv("GITHUB_PAT") != "" {
+		g.hosts["github.com"] = GitHost{
+			Host:     "github.com",
+			Provider: ProviderGitHub,
+			APIURL:   os.Getenv("GITHUB_API_URL"),
+			TokenEnv: "GITHUB_PAT",
+		}
+	}
+
+	slog.Info("Git hosts loaded", "hosts", g.Names())
+	return g, nil
+}
+
+// Names returns the configured hosts, sorted
+func (g *GitHosts) Names() []string {
+	names := make([]string, 0, len(g.hosts))
+	for name := range g.hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookup returns the host configuration for a repository. Local file:// repos
+// need none.
+func (g *GitHosts) lookup(repoURL string) (RepoRef, GitHost, error) {
+	ref, err := parseRepoURL(repoURL)
+	if err != nil {
+		return RepoRef{}, GitHost{}, err
+	}
+	if ref.Scheme == "file" {
+		return ref, GitHost{Provider: ProviderGit}, nil
+	}
+	host, ok := g.hosts[ref.Host]
+	if !ok {
+		return RepoRef{}, GitHost{}, fmt.Errorf("no credentials configured for git host %s", ref.Host)
+	}
+	return ref, host, nil
+}
+
+// RemoteEnv returns the env the wrapper script clones and pushes with:
+// CLONE_URL, plus GIT_USERNAME and GIT_TOKEN for HTTPS or GIT_SSH_KEY and
+// GIT_KNOWN_HOSTS for a deploy key
+func (g *GitHosts) RemoteEnv(repoURL string) ([]string, error) {
+	ref, host, err := g.lookup(repoURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if ref.Scheme == "file" {
+		return []string{"CLONE_URL=" + ref.URL}, nil
+	}
+
+	if host.SSHKeyEnv != "" {
+		key := os.Getenv(host.SSHKeyEnv)
+		if key == "" {
+			return nil, fmt.Errorf("%s not set", host.SSHKeyEnv)
+		}
+		sshHost := ref.Host
+		if i := strings.LastIndexByte(sshHost, ':'); i >= 0 {
+			sshHost = sshHost[:i]
+		}
+		if host.SSHPort != 0 {
+			sshHost += ":" + strconv.Itoa(host.SSHPort)
+		}
+		knownHosts := os.Getenv(host.KnownHostsEnv)
+		if knownHosts == "" {
+			return nil, fmt.Errorf("%s not set", host.KnownHostsEnv)
+		}
+		return []string{
+			"CLONE_URL=ssh://git@" + sshHost + "/" + ref.Path + ".git",
+			"GIT_SSH_KEY=" + key,
+			"GIT_KNOWN_HOSTS=" + knownHosts,
+		}, nil
+	}
+
+	token, err := host.token()
+	if err != nil {
+		return nil, err
+	}
+	env := []string{"CLONE_URL=https://" + ref.Host + "/" + ref.Path + ".git"}
+	if token != "" {
+		env = append(env, "GIT_USERNAME="+host.username(), "GIT_TOKEN="+token)
+	}
+	return env, nil
+}
+
+// Provider returns the pull request API for a repository, or nil if its host
+// has none (plain git remotes and file:// repos)
+func (g *GitHosts) Provider(repoURL string) (GitProvider, RepoRef, error) {
+	ref, host, err := g.lookup(repoURL)
+	if err != nil {
+		return nil, RepoRef{}, err
+	}
+	if host.Provider == ProviderGit {
+		return nil, ref, nil
+	}
+
+	token, err := host.token()
+	if err != nil {
+		return nil, RepoRef{}, err
+	}
+	switch host.Provider {
+	case ProviderGitLab:
+		return NewGitLabClient(host.apiURL(), token), ref, nil
+	case ProviderBitbucket:
+		return NewBitbucketClient(host.apiURL(), token), ref, nil
+	case ProviderGitea:
+		// Gitea's pull request API follows GitHub's
+		client := NewGitHubClient(host.apiURL(), token)
+		client.provider = ProviderGitea
+		return client, ref, nil
+	default:
+		return NewGitHubClient(host.apiURL(), token), ref, nil
+	}
+}
+
+// GitAPIError is returned for non-2xx responses from a git host's API
+type GitAPIError struct {
+	Provider   GitProviderKind
+	StatusCode int
+	Message    string
+}
+
+func (e *GitAPIError) Error() string {
+	return fmt.Sprintf("%s api returned %d: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// doGitAPI sends a JSON request to a git host's API and decodes the response
+// into out. header sets the provider's auth and content headers.
+func doGitAPI(ctx context.Context, client *http.Client, provider GitProviderKind, method, url string, in, out interface{}, header func(*http.Request)) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "Highline-AutoFix")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	header(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// GitHub, Gitea and GitLab send "message"; GitLab may send "error";
+		// Bitbucket nests it under "error"
+		var apiErr struct {
+			Message json.RawMessage `json:"message"`
+			Error   json.RawMessage `json:"error"`
+			Errors  []struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"errors"` // GitHub validation failures, e.g. a pull request that already exists
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&apiErr)
+		message := apiErrorMessage(apiErr.Message, apiErr.Error)
+		for _, e := range apiErr.Errors {
+			if e.Message != "" {
+				message += ": " + e.Message
+			} else if e.Code != "" {
+				message += ": " + e.Code
+			}
+		}
+		return &GitAPIError{Provider: provider, StatusCode: resp.StatusCode, Message: message}
+	}
+
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+// apiErrorMessage flattens the error fields of the supported APIs to text
+func apiErrorMessage(fields ...json.RawMessage) string {
+	for _, field := range fields {
+		if len(field) == 0 {
+			continue
+		}
+		var text string
+		if json.Unmarshal(field, &text) == nil {
+			return text
+		}
+		var nested struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(field, &nested) == nil && nested.Message != "" {
+			return nested.Message
+		}
+		return string(field)
+	}
+	return ""
+}
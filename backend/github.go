@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// GitHubClient is a minimal client for the GitHub REST API. Gitea serves the
+// same pull request endpoints under /api/v1, so it is used for Gitea too.
+type GitHubClient struct {
+	baseURL    string
+	token      string
+	provider   GitProviderKind // github or gitea, for errors
+	httpClient *http.Client
+}
+
+// NewGitHubClient creates a client for the API at baseURL (e.g. https://api.github.com)
+func NewGitHubClient(baseURL, token string) *GitHubClient {
+	return &GitHubClient{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		token:      token,
+		provider:   ProviderGitHub,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// PullRequest is the subset of the GitHub pull request object Highline uses.
+// Other providers' pull and merge requests are mapped onto it.
+type PullRequest struct {
+	Number   int        `json:"number"`
+	HTMLURL  string     `json:"html_url"`
+	State    string     `json:"state"` // "open" or "closed"
+	Merged   bool       `json:"merged"`
+	MergedAt *time.Time `json:"merged_at"`
+}
+
+// NewPullRequest is the request body for creating a pull request
+type NewPullRequest struct {
+	Title string `json:"title"`
+	Head  string `json:"head"`
+	Base  string `json:"base"`
+	Body  string `json:"body"`
+}
+
+// do sends a request and decodes the JSON response into out
+func (c *GitHubClient) do(ctx context.Context, method, path string, in, out interface{}) error {
+	return doGitAPI(ctx, c.httpClient, c.provider, method, c.baseURL+path, in, out, func(req *http.Request) {
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+	})
+}
+
+// GetDefaultBranch returns the default branch of a repository
+func (c *GitHubClient) GetDefaultBranch(ctx context.Context, repo RepoRef) (string, error) {
+	var info struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s", repo.Owner(), repo.Name()), nil, &info); err != nil {
+		return "", err
+	}
+	return info.DefaultBranch, nil
+}
+
+// CreatePullRequest opens a pull request
+func (c *GitHubClient) CreatePullRequest(ctx context.Context, repo RepoRef, pr NewPullRequest) (*PullRequest, error) {
+	var created PullRequest
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/%s/pulls", repo.Owner(), repo.Name()), pr, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// GetPullRequest fetches a pull request by number
+func (c *GitHubClient) GetPullRequest(ctx context.Context, repo RepoRef, number int) (*PullRequest, error) {
+	var pr PullRequest
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s/pulls/%d", repo.Owner(), repo.Name(), number), nil, &pr); err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+// AddLabels adds labels to an issue or pull request
+func (c *GitHubClient) AddLabels(ctx context.Context, repo RepoRef, number int, labels []string) error {
+	body := map[string][]string{"labels": labels}
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/%s/issues/%d/labels", repo.Owner(), repo.Name(), number), body, nil)
+}
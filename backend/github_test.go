@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeGitHub serves the parts of the GitHub REST API the client uses
+func fakeGitHub(t *testing.T) (*httptest.Server, *[]NewPullRequest) {
+	t.Helper()
+	var created []NewPullRequest
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/acme/api", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"default_branch": "trunk"})
+	})
+	mux.HandleFunc("POST /repos/acme/api/pulls", func(w http.ResponseWriter, r *http.Request) {
+		var pr NewPullRequest
+		if err := json.NewDecoder(r.Body).Decode(&pr); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, existing := range created {
+			if existing.Head == pr.Head {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				w.Write([]byte(`{"message": "Validation Failed", "errors": [{"resource": "PullRequest", "code": "custom",
+					"message": "A pull request already exists for acme:` + pr.Head + `."}]}`))
+				return
+			}
+		}
+		created = append(created, pr)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(PullRequest{
+			Number:  len(created),
+			HTMLURL: "https://github.com/acme/api/pull/1",
+			State:   "open",
+		})
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message": "Bad credentials"}`))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, &created
+}
+
+func TestGitHubGetDefaultBranch(t *testing.T) {
+	server, _ := fakeGitHub(t)
+	repo, _ := parseRepoURL("https://github.com/acme/api")
+
+	branch, err := NewGitHubClient(server.URL, "test-token").GetDefaultBranch(context.Background(), repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branch != "trunk" {
+		t.Errorf("default branch = %q, want trunk", branch)
+	}
+
+	_, err = NewGitHubClient(server.URL, "wrong").GetDefaultBranch(context.Background(), repo)
+	var apiErr *GitAPIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Message != "Bad credentials" {
+		t.Errorf("bad token: err = %v, want a 401 GitAPIError", err)
+	}
+}
+
+func TestGitHubCreatePullRequest(t *testing.T) {
+	server, created := fakeGitHub(t)
+	client := NewGitHubClient(server.URL+"/", "test-token")
+	repo, _ := parseRepoURL("https://github.com/acme/api.git")
+	newPR := NewPullRequest{Title: "fix", Head: fixBranchName("abc123"), Base: "trunk", Body: "body"}
+
+	pr, err := client.CreatePullRequest(context.Background(), repo, newPR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.Number != 1 || pr.HTMLURL != "https://github.com/acme/api/pull/1" || pr.State != "open" {
+		t.Errorf("pull request = %+v", pr)
+	}
+	if len(*created) != 1 || (*created)[0] != newPR {
+		t.Errorf("server received %+v, want %+v", *created, newPR)
+	}
+
+	// A second pull request for the same branch is rejected
+	_, err = client.CreatePullRequest(context.Background(), repo, newPR)
+	var apiErr *GitAPIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("duplicate: err = %v, want a 422 GitAPIError", err)
+	}
+	if !strings.Contains(apiErr.Message, "A pull request already exists") {
+		t.Errorf("duplicate: message = %q, want the validation error", apiErr.Message)
+	}
+}
+
+func TestBuildPullRequestBody(t *testing.T) {
+	record := &RemediationRecord{
+		ID:          "abc123",
+		ServiceName: "api",
+		RepoPath:    "services/api",
+		ErrorLog:    "panic: nil map\n```\ninjected fence",
+		AgentReport: &AgentReport{
+			Summary:      "Initialise the map before use",
+			FilesChanged: []string{"services/api/main.go"},
+			CommitHash:   "0123abcd",
+			Tests: &TestRun{
+				Command:  "go test ./...",
+				Result:   TestsFailed,
+				ExitCode: 1,
+				Output:   "--- FAIL: TestMap",
+			},
+		},
+	}
+
+	body := buildPullRequestBody(record)
+	for _, want := range []string{
+		"**api** (remediation `abc123`)",
+		"Changes are limited to `services/api/`.",
+		"panic: nil map",
+		"### Agent summary\n\nInitialise the map before use",
+		"- `services/api/main.go`",
+		"### Tests: failed",
+		"`go test ./...` exited with code 1.",
+		"--- FAIL: TestMap",
+		"Commit: 0123abcd",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body is missing %q:\n%s", want, body)
+		}
+	}
+	// The error log cannot close the code block it is quoted in
+	if strings.Count(body, "```") != 4 {
+		t.Errorf("body has %d code fences, want 4:\n%s", strings.Count(body, "```"), body)
+	}
+
+	record.ErrorLog = strings.Repeat("x", maxPRErrorLogBytes+100)
+	record.AgentReport = nil
+	body = buildPullRequestBody(record)
+	if !strings.Contains(body, "... (truncated)") || strings.Contains(body, "### Agent summary") {
+		t.Errorf("long log without report: body = %q", body[len(body)-200:])
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GitLabClient opens and follows merge requests through the GitLab REST API
+type GitLabClient struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewGitLabClient creates a client for the API at baseURL (e.g. https://gitlab.com/api/v4)
+func NewGitLabClient(baseURL, token string) *GitLabClient {
+	return &GitLabClient{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// gitLabMergeRequest is the subset of the GitLab merge request object Highline uses
+type gitLabMergeRequest struct {
+	IID      int        `json:"iid"`
+	WebURL   string     `json:"web_url"`
+	State    string     `json:"state"` // opened, closed, locked or merged
+	MergedAt *time.Time `json:"merged_at"`
+}
+
+func (mr *gitLabMergeRequest) pullRequest() *PullRequest {
+	pr := &PullRequest{
+		Number:   mr.IID,
+		HTMLURL:  mr.WebURL,
+		State:    "open",
+		Merged:   mr.State == "merged",
+		MergedAt: mr.MergedAt,
+	}
+	if mr.State == "closed" || mr.State == "merged" {
+		pr.State = "closed"
+	}
+	return pr
+}
+
+// project returns the API path of a project, addressed by its full path
+func (c *GitLabClient) project(repo RepoRef) string {
+	return "/projects/" + url.PathEscape(repo.Path)
+}
+
+// do sends a request and decodes the JSON response into out
+func (c *GitLabClient) do(ctx context.Context, method, path string, in, out interface{}) error {
+	return doGitAPI(ctx, c.httpClient, ProviderGitLab, method, c.baseURL+path, in, out, func(req *http.Request) {
+		if c.token != "" {
+			req.Header.Set("PRIVATE-TOKEN", c.token)
+		}
+	})
+}
+
+// GetDefaultBranch returns the default branch of a project
+func (c *GitLabClient) GetDefaultBranch(ctx context.Context, repo RepoRef) (string, error) {
+	var info struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := c.do(ctx, http.MethodGet, c.project(repo), nil, &info); err != nil {
+		return "", err
+	}
+	return info.DefaultBranch, nil
+}
+
+// CreatePullRequest opens a merge request
+func (c *GitLabClient) CreatePullRequest(ctx context.Context, repo RepoRef, pr NewPullRequest) (*PullRequest, error) {
+	body := map[string]interface{}{
+		"source_branch":        pr.Head,
+		"target_branch":        pr.Base,
+		"title":                pr.Title,
+		"description":          pr.Body,
+		"remove_source_branch": true,
+	}
+	var created gitLabMergeRequest
+	if err := c.do(ctx, http.MethodPost, c.project(repo)+"/merge_requests", body, &created); err != nil {
+		return nil, err
+	}
+	return created.pullRequest(), nil
+}
+
+// GetPullRequest fetches a merge request by its project-level ID (iid)
+func (c *GitLabClient) GetPullRequest(ctx context.Context, repo RepoRef, number int) (*PullRequest, error) {
+	var mr gitLabMergeRequest
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("%s/merge_requests/%d", c.project(repo), number), nil, &mr); err != nil {
+		return nil, err
+	}
+	return mr.pullRequest(), nil
+}
+
+// AddLabels adds labels to a merge request
+func (c *GitLabClient) AddLabels(ctx context.Context, repo RepoRef, number int, labels []string) error {
+	body := map[string]string{"add_labels": strings.Join(labels, ",")}
+	return c.do(ctx, http.MethodPut, fmt.Sprintf("%s/merge_requests/%d", c.project(repo), number), body, nil)
+}
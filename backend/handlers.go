@@ -2,8 +2,12 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -26,8 +30,50 @@ func (app *App) HeartbeatHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "service_name is required", http.StatusBadRequest)
 		return
 	}
+	if err := validateServiceName(req.ServiceName); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.GitHubRepo != "" {
+		if err := validateHTTPSRepoURL(req.GitHubRepo); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 
-	service := app.store.RecordHeartbeat(req)
+	if status, ok := app.authorizeIngest(r, req.ServiceName); !ok {
+		slog.Warn("Rejected heartbeat with invalid ingest token", "service", req.ServiceName)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	if req.Check != nil {
+		if err := req.Check.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if _, err := app.processHeartbeat(req); errors.Is(err, ErrServiceNotRegistered) {
+		slog.Warn("Rejected heartbeat from unregistered service", "service", req.ServiceName)
+		http.Error(w, "Service is not registered", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "ok",
+		"message": "Heartbeat recorded",
+	})
+}
+
+// processHeartbeat records a heartbeat, broadcasts the update and triggers
+// remediation on errors. Shared by pushed heartbeats and active probes.
+func (app *App) processHeartbeat(req HeartbeatRequest) (*Service, error) {
+	service, err := app.store.RecordHeartbeat(req)
+	if err != nil {
+		return nil, err
+	}
 
 	slog.Info("Heartbeat received",
 		"service", req.ServiceName,
@@ -39,7 +85,7 @@ func (app *App) HeartbeatHandler(w http.ResponseWriter, r *http.Request) {
 	app.BroadcastServiceUpdate(service)
 
 	// If service reported an error, trigger remediation
-	if req.Status == "error" && req.ErrorLog != "" && req.GitHubRepo != "" {
+	if req.Status == "error" && req.ErrorLog != "" && service.GitHubRepo != "" {
 		slog.Warn("Service reported error, triggering remediation",
 			"service", req.ServiceName,
 			"error", req.ErrorLog,
@@ -47,11 +93,7 @@ func (app *App) HeartbeatHandler(w http.ResponseWriter, r *http.Request) {
 		go app.TriggerRemediation(service, req.ErrorLog)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":  "ok",
-		"message": "Heartbeat recorded",
-	})
+	return service, nil
 }
 
 // ServicesHandler returns all services
@@ -67,13 +109,8 @@ func (app *App) ServicesHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(services)
 }
 
-// ServiceHandler returns a specific service
+// ServiceHandler returns, registers, updates or deletes a specific service
 func (app *App) ServiceHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	// Extract service name from path: /services/{name} or /api/services/{name}
 	path := strings.TrimPrefix(r.URL.Path, "/api/services/")
 	path = strings.TrimPrefix(path, "/services/")
@@ -82,14 +119,174 @@ func (app *App) ServiceHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	service, exists := app.store.GetService(path)
+	// Sub-resources: /api/services/{name}/uptime
+	if name, ok := strings.CutSuffix(path, "/uptime"); ok {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		app.serviceUptime(w, r, name)
+		return
+	}
+
+	// /api/services/{name}/remediate
+	if name, ok := strings.CutSuffix(path, "/remediate"); ok {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if app.refuseWithoutAdminKey(w) {
+			return
+		}
+		app.remediateService(w, r, name)
+		return
+	}
+
+	if strings.Contains(path, "/") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		service, exists := app.store.GetService(path)
+		if !exists {
+			http.Error(w, "Service not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(service)
+
+	case http.MethodPost, http.MethodPut:
+		app.registerService(w, r, path)
+
+	case http.MethodDelete:
+		if !app.store.DeleteService(path) {
+			http.Error(w, "Service not found", http.StatusNotFound)
+			return
+		}
+		// A deleted service never recovers, so close its incident now
+		if incident, ok := app.incidents.Resolve(path); ok {
+			slog.Info("Incident resolved",
+				"incident", incident.ID,
+				"service", path,
+				"reason", "service deleted",
+			)
+			app.wsHub.Broadcast("incident_update", incident)
+		}
+		app.wsHub.Broadcast("service_removed", map[string]string{"name": path})
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// registerService handles POST (create) and PUT (create or replace) registrations
+func (app *App) registerService(w http.ResponseWriter, r *http.Request, name string) {
+	var reg ServiceRegistration
+	if err := json.NewDecoder(r.Body).Decode(&reg); err != nil {
+		slog.Error("Failed to decode service registration", "error", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := validateServiceName(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := reg.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if policy := reg.RemediationPolicy; policy != nil && policy.Agent != nil && policy.Agent.Provider != "" &&
+		!app.remediation.agents.Has(policy.Agent.Provider) {
+		http.Error(w, fmt.Sprintf("unknown agent provider %q (available: %s)",
+			policy.Agent.Provider, strings.Join(app.remediation.agents.Names(), ", ")), http.StatusBadRequest)
+		return
+	}
+	if policy := reg.RemediationPolicy; policy != nil && policy.Agent != nil {
+		if _, err := app.remediation.agents.Resolve(policy.Agent); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var service *Service
+	status := http.StatusOK
+	if r.Method == http.MethodPost {
+		registered, ok := app.store.RegisterService(name, reg)
+		if !ok {
+			http.Error(w, "Service already registered", http.StatusConflict)
+			return
+		}
+		service = registered
+		status = http.StatusCreated
+	} else {
+		updated, created := app.store.UpdateRegistration(name, reg)
+		service = updated
+		if created {
+			status = http.StatusCreated
+		}
+	}
+
+	app.BroadcastServiceUpdate(service)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(service)
+}
+
+// serviceUptime returns the uptime history of a service
+// Query params: window (default 24h), resolution (default 1h); 0 means the default
+func (app *App) serviceUptime(w http.ResponseWriter, r *http.Request, name string) {
+	window := 24 * time.Hour
+	if v := r.URL.Query().Get("window"); v != "" {
+		parsed, err := parseWindow(v)
+		if err != nil {
+			http.Error(w, "Invalid window", http.StatusBadRequest)
+			return
+		}
+		if parsed > 0 {
+			window = parsed
+		}
+	}
+
+	resolution := time.Hour
+	if v := r.URL.Query().Get("resolution"); v != "" {
+		parsed, err := parseWindow(v)
+		if err != nil {
+			http.Error(w, "Invalid resolution", http.StatusBadRequest)
+			return
+		}
+		if parsed > 0 {
+			resolution = parsed
+		}
+	}
+
+	if window > uptimeRetention {
+		http.Error(w, "Window exceeds retention of "+formatWindow(uptimeRetention), http.StatusBadRequest)
+		return
+	}
+	if resolution < uptimeBucketSize || resolution%uptimeBucketSize != 0 {
+		http.Error(w, "Resolution must be a multiple of "+formatWindow(uptimeBucketSize), http.StatusBadRequest)
+		return
+	}
+	if window/resolution > maxUptimeBuckets {
+		http.Error(w, "Too many buckets - use a coarser resolution", http.StatusBadRequest)
+		return
+	}
+
+	report, exists := app.store.GetUptime(name, window, resolution)
 	if !exists {
 		http.Error(w, "Service not found", http.StatusNotFound)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(service)
+	json.NewEncoder(w).Encode(report)
 }
 
 // HealthHandler returns the health status of the monitoring service itself
@@ -104,8 +301,8 @@ func (app *App) HealthHandler(w http.ResponseWriter, r *http.Request) {
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusOK)
@@ -145,17 +342,26 @@ func (app *App) RemediationsHandler(w http.ResponseWriter, r *http.Request) {
 		records = app.remediationStore.GetAll()
 	}
 
+	// ?status=pending lists the queue
+	if status := RemediationStatus(r.URL.Query().Get("status")); status != "" {
+		filtered := make([]RemediationRecord, 0)
+		for _, record := range records {
+			if record.Status == status {
+				filtered = append(filtered, record)
+			}
+		}
+		records = filtered
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(records)
 }
 
-// RemediationDetailHandler returns a specific remediation record
+// RemediationDetailHandler handles a single remediation:
+// GET returns it, DELETE drops it from the queue, GET /api/remediations/{id}/logs
+// and /diff return its agent output and change, and POST to /priority, /cancel
+// or /retry acts on it
 func (app *App) RemediationDetailHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	// Extract ID from path: /api/remediations/{id}
 	path := strings.TrimPrefix(r.URL.Path, "/api/remediations/")
 	if path == "" {
@@ -163,31 +369,390 @@ func (app *App) RemediationDetailHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	record, exists := app.remediationStore.Get(path)
+	// Sub-resources: /api/remediations/{id}/logs
+	if id, ok := strings.CutSuffix(path, "/logs"); ok {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		app.remediationLogsHandler(w, r, id)
+		return
+	}
+
+	// /api/remediations/{id}/diff
+	if id, ok := strings.CutSuffix(path, "/diff"); ok {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		app.remediationDiffHandler(w, r, id)
+		return
+	}
+
+	// Actions: /api/remediations/{id}/{priority,cancel,retry}
+	if id, action, ok := strings.Cut(path, "/"); ok {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		switch action {
+		case "priority":
+			app.reprioritizeRemediation(w, r, id)
+		case "cancel":
+			app.cancelRemediation(w, id)
+		case "retry":
+			if app.refuseWithoutAdminKey(w) {
+				return
+			}
+			app.retryRemediation(w, id)
+		default:
+			http.Error(w, "Not found", http.StatusNotFound)
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		record, exists := app.remediationStore.Get(path)
+		if !exists {
+			http.Error(w, "Remediation not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(RemediationDetailResponse{RemediationRecord: record, Context: record.Context})
+
+	case http.MethodDelete:
+		if _, exists := app.remediationStore.Get(path); !exists {
+			http.Error(w, "Remediation not found", http.StatusNotFound)
+			return
+		}
+		if !app.remediationStore.Drop(path) {
+			http.Error(w, "Only queued remediations can be dropped", http.StatusConflict)
+			return
+		}
+		slog.Info("[REMEDIATION] Dropped from queue", "id", path)
+		app.wsHub.Broadcast("remediation_removed", map[string]string{"id": path})
+		app.broadcastQueue()
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// reprioritizeRemediation changes the priority of a queued remediation
+// Body: {"priority": 10}
+func (app *App) reprioritizeRemediation(w http.ResponseWriter, r *http.Request, id string) {
+	var req struct {
+		Priority *int `json:"priority"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Priority == nil {
+		http.Error(w, "priority is required", http.StatusBadRequest)
+		return
+	}
+
+	if _, exists := app.remediationStore.Get(id); !exists {
+		http.Error(w, "Remediation not found", http.StatusNotFound)
+		return
+	}
+	if !app.remediationStore.SetPriority(id, *req.Priority) {
+		http.Error(w, "Only queued remediations can be reprioritized", http.StatusConflict)
+		return
+	}
+
+	slog.Info("[REMEDIATION] Reprioritized", "id", id, "priority", *req.Priority)
+	app.broadcastQueue()
+
+	record, _ := app.remediationStore.Get(id)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(record)
+}
+
+// remediationLogsHandler returns a remediation's buffered agent output from
+// ?offset= (default 0) on
+func (app *App) remediationLogsHandler(w http.ResponseWriter, r *http.Request, id string) {
+	if _, exists := app.remediationStore.Get(id); !exists {
+		http.Error(w, "Remediation not found", http.StatusNotFound)
+		return
+	}
+
+	offset := 0
+	if v := r.URL.Query().Get("offset"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 0 {
+			http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		offset = parsed
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(app.remediationLogs.Since(id, offset))
+}
+
+// remediationDiffHandler returns the change an agent committed, as JSON or,
+// with ?format=patch, as a plain unified diff
+func (app *App) remediationDiffHandler(w http.ResponseWriter, r *http.Request, id string) {
+	record, exists := app.remediationStore.Get(id)
+	if !exists {
+		http.Error(w, "Remediation not found", http.StatusNotFound)
+		return
+	}
+	if record.Diff == nil {
+		http.Error(w, "No diff captured for this remediation", http.StatusNotFound)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "patch" {
+		w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+		io.WriteString(w, record.Diff.Patch)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RemediationDiffResponse{
+		ID:              id,
+		RemediationDiff: *record.Diff,
+		Patch:           record.Diff.Patch,
+	})
+}
+
+// cancelRemediation cancels a queued remediation or stops a running one
+func (app *App) cancelRemediation(w http.ResponseWriter, id string) {
+	record, exists := app.remediationStore.Get(id)
 	if !exists {
 		http.Error(w, "Remediation not found", http.StatusNotFound)
 		return
 	}
 
+	status := http.StatusOK
+	switch {
+	case app.remediationStore.CancelQueued(id):
+		slog.Info("[REMEDIATION] Cancelled while queued", "id", id)
+		app.broadcastQueue()
+	case record.Status == RemediationPending || record.Status == RemediationRunning:
+		// A worker may have just claimed it; the agent stops and the worker
+		// records the cancellation
+		if !app.remediation.Cancel(id) {
+			http.Error(w, "Remediation is starting, try again", http.StatusConflict)
+			return
+		}
+		slog.Info("[REMEDIATION] Cancel requested", "id", id)
+		status = http.StatusAccepted
+	default:
+		http.Error(w, "Remediation already finished", http.StatusConflict)
+		return
+	}
+
+	app.broadcastRemediation(id)
+
+	record, _ = app.remediationStore.Get(id)
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(record)
 }
 
-// RemediationReportHandler receives reports from OpenCode agents
+// retryRemediation queues a new remediation with the same inputs as a finished one
+func (app *App) retryRemediation(w http.ResponseWriter, id string) {
+	original, exists := app.remediationStore.Get(id)
+	if !exists {
+		http.Error(w, "Remediation not found", http.StatusNotFound)
+		return
+	}
+	if original.Status == RemediationPending || original.Status == RemediationRunning {
+		http.Error(w, "Remediation has not finished", http.StatusConflict)
+		return
+	}
+
+	if !app.auth.IsRepoAllowed(original.GitHubRepo) {
+		http.Error(w, "Repository is not allow-listed", http.StatusForbidden)
+		return
+	}
+
+	record, ok := app.startManualRemediation(RemediationRequest{
+		ID:          newRemediationID(),
+		ServiceName: original.ServiceName,
+		GitHubRepo:  original.GitHubRepo,
+		RepoPath:    original.RepoPath,
+		BaseBranch:  original.BaseBranch,
+		ErrorLog:    original.ErrorLog,
+		Signature:   original.ErrorSignature,
+		Mode:        original.Mode,
+		Agent:       original.Agent,
+		Priority:    original.Priority,
+		Prompt:      original.Prompt,
+		TestPolicy:  original.TestPolicy,
+		Context:     original.Context,
+		TriggeredBy: "retry",
+		RetryOf:     original.ID,
+	})
+	if !ok {
+		http.Error(w, "A remediation is already in progress for this service", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(record)
+}
+
+// remediateService queues a remediation requested by a person, bypassing the
+// policy thresholds
+// Body (optional): {"error": "...", "prompt": "...", "mode": "suggest", "priority": 10}
+func (app *App) remediateService(w http.ResponseWriter, r *http.Request, name string) {
+	var req struct {
+		Error    string          `json:"error"`
+		Prompt   string          `json:"prompt"`
+		Mode     RemediationMode `json:"mode"`
+		Priority *int            `json:"priority"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Mode != "" && req.Mode != RemediationModeAuto && req.Mode != RemediationModeSuggest {
+		http.Error(w, "mode must be \"auto\" or \"suggest\"", http.StatusBadRequest)
+		return
+	}
+
+	service, exists := app.store.GetService(name)
+	if !exists {
+		http.Error(w, "Service not found", http.StatusNotFound)
+		return
+	}
+	if service.GitHubRepo == "" {
+		http.Error(w, "Service has no GitHub repo", http.StatusBadRequest)
+		return
+	}
+	if !app.auth.IsRepoAllowed(service.GitHubRepo) {
+		http.Error(w, "Repository is not allow-listed", http.StatusForbidden)
+		return
+	}
+
+	policy := effectivePolicy(service.RemediationPolicy)
+	errorLog := req.Error
+	if errorLog == "" {
+		errorLog = service.LastError
+	}
+	if errorLog == "" {
+		errorLog = "Manual remediation requested"
+	}
+	mode := req.Mode
+	if mode == "" {
+		mode = policy.Mode
+		// The service opted out of automatic fixes, not of being asked for one,
+		// so only push when the caller says so
+		if mode == RemediationModeOff {
+			mode = RemediationModeSuggest
+		}
+	}
+	priority := policy.Priority
+	if req.Priority != nil {
+		priority = *req.Priority
+	}
+
+	record, ok := app.startManualRemediation(RemediationRequest{
+		ID:          newRemediationID(),
+		ServiceName: service.Name,
+		GitHubRepo:  service.GitHubRepo,
+		RepoPath:    service.RepoPath,
+		BaseBranch:  service.BaseBranch,
+		ErrorLog:    errorLog,
+		Signature:   errorSignature(errorLog),
+		Mode:        mode,
+		Agent:       policy.Agent,
+		Priority:    priority,
+		Prompt:      req.Prompt,
+		TestPolicy:  policy.Tests,
+		Context:     buildRemediationContext(service, errorLog, app.contextLogs),
+		TriggeredBy: "manual",
+	})
+	if !ok {
+		http.Error(w, "A remediation is already in progress for this service", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(record)
+}
+
+// startManualRemediation queues a remediation unless the service already has
+// one queued or running
+func (app *App) startManualRemediation(req RemediationRequest) (*RemediationRecord, bool) {
+	if !app.policy.Reserve(req.ServiceName) {
+		return nil, false
+	}
+	defer app.policy.Finished(req.ServiceName)
+
+	for _, existing := range app.remediationStore.GetByService(req.ServiceName) {
+		if existing.Status == RemediationPending || existing.Status == RemediationRunning {
+			return nil, false
+		}
+	}
+
+	app.store.RecordPolicyDecision(req.ServiceName, PolicyDecision{
+		Time:      time.Now(),
+		Action:    PolicyTrigger,
+		Reason:    req.TriggeredBy + " request",
+		Mode:      req.Mode,
+		Signature: req.Signature,
+	})
+	return app.startRemediation(req), true
+}
+
+// maxReportBytes caps the size of an agent report body
+const maxReportBytes = 1 << 20
+
+// RemediationReportHandler receives signed reports from OpenCode agents
 func (app *App) RemediationReportHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxReportBytes))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
 	var report AgentReport
-	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+	if err := decodeAgentMessage(body, &report); err != nil {
 		slog.Error("Failed to decode agent report", "error", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Only the agent holding the per-remediation secret may report
+	err = app.remediationStore.VerifyCallback(report.RemediationID,
+		r.Header.Get(headerTimestamp), r.Header.Get(headerSignature), body)
+	if err != nil {
+		slog.Warn("[AGENT REPORT] Rejected report", "id", report.RemediationID, "error", err)
+		status := http.StatusUnauthorized
+		if errors.Is(err, ErrReplayedReport) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if err := report.Validate(); err != nil {
+		slog.Warn("[AGENT REPORT] Invalid report", "id", report.RemediationID, "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	report.Timestamp = time.Now()
+	report.decodeTestOutput()
+
+	diff, err := takeReportDiff(&report)
+	if err != nil {
+		slog.Warn("[AGENT REPORT] Ignoring diff", "id", report.RemediationID, "error", err)
+	}
 
 	slog.Info("[AGENT REPORT] Received report from OpenCode agent",
 		"remediation_id", report.RemediationID,
@@ -195,19 +760,33 @@ func (app *App) RemediationReportHandler(w http.ResponseWriter, r *http.Request)
 		"files_changed", len(report.FilesChanged),
 		"pushed", report.Pushed,
 	)
+	if report.Tests != nil {
+		slog.Info("[AGENT REPORT] Tests",
+			"remediation_id", report.RemediationID,
+			"result", report.Tests.Result,
+			"exit_code", report.Tests.ExitCode,
+		)
+	}
 
 	if report.Summary != "" {
 		slog.Info("[AGENT REPORT] Summary", "summary", report.Summary)
 	}
 
-	found := app.remediationStore.AddAgentReport(report.RemediationID, &report)
-	if !found {
-		slog.Warn("[AGENT REPORT] Remediation ID not found", "id", report.RemediationID)
+	if _, err := app.remediationStore.AddAgentReport(report.RemediationID, &report); err != nil {
+		slog.Warn("[AGENT REPORT] Rejected report", "id", report.RemediationID, "error", err)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if diff != nil {
+		app.remediationStore.SetDiff(report.RemediationID, diff)
 	}
 
 	// Broadcast update to WebSocket clients
-	if record, exists := app.remediationStore.Get(report.RemediationID); exists {
-		app.wsHub.Broadcast("remediation_update", record)
+	app.broadcastRemediation(report.RemediationID)
+
+	// Open a pull request for the pushed fix branch
+	if report.Success && report.Pushed {
+		go app.openPullRequest(report.RemediationID)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -216,3 +795,261 @@ func (app *App) RemediationReportHandler(w http.ResponseWriter, r *http.Request)
 		"message": "Report received",
 	})
 }
+
+// RemediationProgressHandler receives signed phase events from agents
+func (app *App) RemediationProgressHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxReportBytes))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	var progress AgentProgress
+	if err := decodeAgentMessage(body, &progress); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = app.remediationStore.VerifyCallback(progress.RemediationID,
+		r.Header.Get(headerTimestamp), r.Header.Get(headerSignature), body)
+	if err != nil {
+		slog.Warn("[AGENT PROGRESS] Rejected event", "id", progress.RemediationID, "error", err)
+		status := http.StatusUnauthorized
+		if errors.Is(err, ErrReplayedReport) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if err := progress.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	event := PhaseEvent{Phase: progress.Phase, Time: time.Now(), Detail: progress.Detail}
+	if _, err := app.remediationStore.AddPhase(progress.RemediationID, event); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	slog.Info("[AGENT PROGRESS] Phase reached",
+		"id", progress.RemediationID,
+		"phase", progress.Phase,
+		"detail", progress.Detail,
+	)
+	app.wsHub.Broadcast("remediation_phase", map[string]interface{}{
+		"id":    progress.RemediationID,
+		"event": event,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// IncidentsHandler returns incidents, optionally filtered by service and status
+func (app *App) IncidentsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	serviceName := r.URL.Query().Get("service")
+	status := IncidentStatus(r.URL.Query().Get("status"))
+	if status != "" && status != IncidentOpen && status != IncidentResolved {
+		http.Error(w, "status must be open or resolved", http.StatusBadRequest)
+		return
+	}
+
+	incidents := app.incidents.List(serviceName, status)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(incidents)
+}
+
+// IncidentDetailHandler returns a specific incident
+func (app *App) IncidentDetailHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract ID from path: /api/incidents/{id}
+	path := strings.TrimPrefix(r.URL.Path, "/api/incidents/")
+	if path == "" {
+		http.Error(w, "Incident ID required", http.StatusBadRequest)
+		return
+	}
+
+	incident, exists := app.incidents.Get(path)
+	if !exists {
+		http.Error(w, "Incident not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(incident)
+}
+
+// TokensHandler lists (GET) and issues (POST) API tokens
+func (app *App) TokensHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(app.auth.ListTokens())
+
+	case http.MethodPost:
+		var req struct {
+			Kind        TokenKind `json:"kind"`
+			ServiceName string    `json:"service_name"`
+			Description string    `json:"description"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Kind == "" {
+			req.Kind = TokenIngest
+		}
+		if req.Kind != TokenIngest && req.Kind != TokenAdmin {
+			http.Error(w, "kind must be ingest or admin", http.StatusBadRequest)
+			return
+		}
+		if req.Kind == TokenIngest && req.ServiceName == "" {
+			http.Error(w, "service_name is required for ingest tokens", http.StatusBadRequest)
+			return
+		}
+		if req.ServiceName != "" {
+			if err := validateServiceName(req.ServiceName); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		token, secret, err := app.auth.CreateToken(req.Kind, req.ServiceName, req.Description)
+		if err != nil {
+			slog.Error("Failed to create token", "error", err)
+			http.Error(w, "Failed to create token", http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info("API token created", "id", token.ID, "kind", token.Kind, "service", token.ServiceName)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(struct {
+			*APIToken
+			Token string `json:"token"` // only returned once
+		}{token, secret})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// TokenHandler revokes a specific API token
+func (app *App) TokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract ID from path: /api/tokens/{id}
+	id := strings.TrimPrefix(r.URL.Path, "/api/tokens/")
+	if id == "" {
+		http.Error(w, "Token ID required", http.StatusBadRequest)
+		return
+	}
+
+	if !app.auth.RevokeToken(id) {
+		http.Error(w, "Token not found", http.StatusNotFound)
+		return
+	}
+
+	slog.Info("API token revoked", "id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AlertTestHandler sends a test alert to a notifier
+// POST {"notifier": "..."}
+func (app *App) AlertTestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Notifier string `json:"notifier"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Notifier == "" {
+		http.Error(w, "notifier is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := app.alerts.Test(req.Notifier); err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, ErrUnknownNotifier) {
+			status = http.StatusNotFound
+		}
+		slog.Warn("[ALERT] Test alert failed", "notifier", req.Notifier, "error", err)
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "ok",
+		"message": "Test alert sent to " + req.Notifier,
+	})
+}
+
+// AllowedReposHandler manages the remediation repo allow-list
+// GET lists, POST {"repo": "..."} adds, DELETE ?repo=... removes
+func (app *App) AllowedReposHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(app.auth.ListAllowedRepos())
+
+	case http.MethodPost:
+		var req struct {
+			Repo string `json:"repo"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Repo == "" {
+			http.Error(w, "repo is required", http.StatusBadRequest)
+			return
+		}
+		if err := validateRepoURL(strings.TrimSpace(req.Repo)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		entry := app.auth.AllowRepo(req.Repo)
+		slog.Info("Repository allow-listed for remediation", "repo", entry.Repo)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(entry)
+
+	case http.MethodDelete:
+		repo := r.URL.Query().Get("repo")
+		if repo == "" {
+			http.Error(w, "repo query parameter is required", http.StatusBadRequest)
+			return
+		}
+		if !app.auth.DisallowRepo(repo) {
+			http.Error(w, "Repository not found", http.StatusNotFound)
+			return
+		}
+
+		slog.Info("Repository removed from remediation allow-list", "repo", repo)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
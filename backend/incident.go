@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IncidentStatus represents whether an incident is still ongoing
+type IncidentStatus string
+
+const (
+	IncidentOpen     IncidentStatus = "open"
+	IncidentResolved IncidentStatus = "resolved"
+)
+
+// Incident tracks a period during which a service was unhealthy
+type Incident struct {
+	ID             string         `json:"id"`
+	ServiceName    string         `json:"service_name"`
+	Status         IncidentStatus `json:"status"`
+	Cause          ServiceStatus  `json:"cause"` // status that opened the incident: "error" or "down"
+	TriggerError   string         `json:"trigger_error,omitempty"`
+	StartTime      time.Time      `json:"start_time"`
+	EndTime        *time.Time     `json:"end_time,omitempty"`
+	Duration       string         `json:"duration,omitempty"`
+	RemediationIDs []string       `json:"remediation_ids,omitempty"`
+}
+
+// maxIncidents is how many incidents are kept
+const maxIncidents = 500
+
+// IncidentStore manages incident history
+type IncidentStore struct {
+	mu        sync.RWMutex
+	incidents map[string]*Incident
+	order     []string          // Track insertion order for listing
+	open      map[string]string // service name -> open incident ID
+	storage   Storage
+}
+
+// NewIncidentStore creates a new incident store and reloads persisted incidents
+func NewIncidentStore(storage Storage) *IncidentStore {
+	s := &IncidentStore{
+		incidents: make(map[string]*Incident),
+		order:     make([]string, 0),
+		open:      make(map[string]string),
+		storage:   storage,
+	}
+
+	loaded := make([]*Incident, 0)
+	err := storage.Load(bucketIncidents, func(key string, data []byte) error {
+		var incident Incident
+		if err := json.Unmarshal(data, &incident); err != nil {
+			slog.Warn("Skipping unreadable stored incident", "id", key, "error", err)
+			return nil
+		}
+		loaded = append(loaded, &incident)
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to load incidents from storage", "error", err)
+	}
+
+	sort.Slice(loaded, func(i, j int) bool {
+		return loaded[i].StartTime.Before(loaded[j].StartTime)
+	})
+	for _, incident := range loaded {
+		s.incidents[incident.ID] = incident
+		s.order = append(s.order, incident.ID)
+		if incident.Status == IncidentOpen {
+			s.open[incident.ServiceName] = incident.ID
+		}
+	}
+
+	slog.Info("Incidents loaded from storage", "count", len(s.order), "open", len(s.open))
+	return s
+}
+
+// persist writes an incident to storage. Must be called with s.mu held.
+func (s *IncidentStore) persist(incident *Incident) {
+	if err := s.storage.Put(bucketIncidents, incident.ID, incident); err != nil {
+		slog.Error("Failed to persist incident", "id", incident.ID, "error", err)
+	}
+}
+
+// Open starts a new incident for a service.
+// Returns false if the service already has an open incident.
+func (s *IncidentStore) Open(serviceName string, cause ServiceStatus, triggerError string) (*Incident, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id, exists := s.open[serviceName]; exists {
+		copy := *s.incidents[id]
+		return &copy, false
+	}
+
+	incident := &Incident{
+		ID:           uuid.New().String()[:8],
+		ServiceName:  serviceName,
+		Status:       IncidentOpen,
+		Cause:        cause,
+		TriggerError: triggerError,
+		StartTime:    time.Now(),
+	}
+
+	s.incidents[incident.ID] = incident
+	s.order = append(s.order, incident.ID)
+	s.open[serviceName] = incident.ID
+	s.persist(incident)
+
+	// Keep only the last 500 incidents, dropping the oldest resolved ones:
+	// an open incident stays until its service recovers
+	for i := 0; len(s.order) > maxIncidents && i < len(s.order); {
+		oldID := s.order[i]
+		if old := s.incidents[oldID]; old != nil && old.Status == IncidentOpen {
+			i++
+			continue
+		}
+		delete(s.incidents, oldID)
+		s.order = append(s.order[:i], s.order[i+1:]...)
+		if err := s.storage.Delete(bucketIncidents, oldID); err != nil {
+			slog.Error("Failed to delete incident from storage", "id", oldID, "error", err)
+		}
+	}
+
+	copy := *incident
+	return &copy, true
+}
+
+// Resolve closes the open incident for a service.
+// Returns false if the service has no open incident.
+func (s *IncidentStore) Resolve(serviceName string) (*Incident, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, exists := s.open[serviceName]
+	if !exists {
+		return nil, false
+	}
+	delete(s.open, serviceName)
+
+	incident := s.incidents[id]
+	now := time.Now()
+	incident.Status = IncidentResolved
+	incident.EndTime = &now
+	incident.Duration = now.Sub(incident.StartTime).Round(time.Second).String()
+	s.persist(incident)
+
+	copy := *incident
+	return &copy, true
+}
+
+// LinkRemediation attaches a remediation to the open incident of a service.
+// Returns false if the service has no open incident.
+func (s *IncidentStore) LinkRemediation(serviceName, remediationID string) (*Incident, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, exists := s.open[serviceName]
+	if !exists {
+		return nil, false
+	}
+
+	incident := s.incidents[id]
+	incident.RemediationIDs = append(incident.RemediationIDs, remediationID)
+	s.persist(incident)
+
+	copy := *incident
+	return &copy, true
+}
+
+// Get retrieves a single incident
+func (s *IncidentStore) Get(id string) (*Incident, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	incident, exists := s.incidents[id]
+	if !exists {
+		return nil, false
+	}
+
+	copy := *incident
+	return &copy, true
+}
+
+// List returns incidents (newest first), optionally filtered by service and status
+func (s *IncidentStore) List(serviceName string, status IncidentStatus) []Incident {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	incidents := make([]Incident, 0)
+	for i := len(s.order) - 1; i >= 0; i-- {
+		incident, exists := s.incidents[s.order[i]]
+		if !exists {
+			continue
+		}
+		if serviceName != "" && incident.ServiceName != serviceName {
+			continue
+		}
+		if status != "" && incident.Status != status {
+			continue
+		}
+		incidents = append(incidents, *incident)
+	}
+	return incidents
+}
+
+// handleStatusChange opens and resolves incidents as services change status
+func (app *App) handleStatusChange(change StatusChange) {
+	var incident *Incident
+	var changed bool
+
+	switch change.To {
+	case StatusError, StatusDown:
+		incident, changed = app.incidents.Open(change.Service.Name, change.To, change.Reason)
+		if changed {
+			slog.Warn("Incident opened",
+				"incident", incident.ID,
+				"service", change.Service.Name,
+				"cause", change.To,
+			)
+		}
+	case StatusHealthy:
+		incident, changed = app.incidents.Resolve(change.Service.Name)
+		if changed {
+			slog.Info("Incident resolved",
+				"incident", incident.ID,
+				"service", change.Service.Name,
+				"duration", incident.Duration,
+			)
+		}
+	}
+
+	if changed {
+		app.wsHub.Broadcast("incident_update", incident)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestIncidentPruningKeepsOpenIncidents(t *testing.T) {
+	storage := NewMemoryStorage()
+	incidents := NewIncidentStore(storage)
+	oldest, _ := incidents.Open("still-down", StatusDown, "")
+	for i := 0; i < maxIncidents+10; i++ {
+		name := fmt.Sprintf("svc-%d", i)
+		incidents.Open(name, StatusError, "boom")
+		incidents.Resolve(name)
+	}
+
+	if len(incidents.List("", "")) != maxIncidents {
+		t.Errorf("kept %d incidents, want %d", len(incidents.List("", "")), maxIncidents)
+	}
+	if _, ok := incidents.Get(oldest.ID); !ok {
+		t.Fatal("the open incident was pruned")
+	}
+	if resolved, ok := incidents.Resolve("still-down"); !ok || resolved.ID != oldest.ID {
+		t.Errorf("Resolve = %+v, %v, want the oldest incident", resolved, ok)
+	}
+	if reloaded := NewIncidentStore(storage); len(reloaded.List("", "")) != maxIncidents {
+		t.Errorf("reloaded %d incidents, want %d", len(reloaded.List("", "")), maxIncidents)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -17,6 +19,18 @@ type App struct {
 	store            *ServiceStore
 	remediation      *RemediationService
 	remediationStore *RemediationStore
+	remediationLogs  *RemediationLogs
+	incidents        *IncidentStore
+	auth             *AuthStore
+	gitHosts         *GitHosts
+	alerts           *Alerter
+	prober           *Prober
+	policy           *PolicyEngine
+	queue            *RemediationQueue
+	tracker          *FixTracker
+	jobRetention     JobRetention
+	contextLogs      int    // log entries in a remediation's context bundle
+	webhookSecret    string // GITHUB_WEBHOOK_SECRET; webhooks are rejected when empty
 	wsHub            *WSHub
 }
 
@@ -49,37 +63,154 @@ func main() {
 		}
 	}
 
+	allowUnregistered := true
+	if v := os.Getenv("ALLOW_UNREGISTERED_HEARTBEATS"); v != "" {
+		if parsed, err := strconv.ParseBool(v); err == nil {
+			allowUnregistered = parsed
+		}
+	}
+
 	// Initialize services
-	store := NewServiceStore(timeout)
-	remediationStore := NewRemediationStore()
-	remediation := NewRemediationService(remediationStore)
+	storage, err := NewStorageFromEnv()
+	if err != nil {
+		slog.Error("Invalid storage configuration", "error", err)
+		os.Exit(1)
+	}
+	defer storage.Close()
+
+	store := NewServiceStore(timeout, allowUnregistered, storage)
+	remediationStore := NewRemediationStore(storage)
+	incidents := NewIncidentStore(storage)
+	auth := NewAuthStore(os.Getenv("ADMIN_API_KEY"), storage)
+	if !auth.Enabled() {
+		slog.Warn("ADMIN_API_KEY not set - API authentication is disabled")
+	}
+
+	// Seed the remediation allow-list from the environment
+	for _, repo := range strings.Split(os.Getenv("ALLOWED_REPOS"), ",") {
+		if repo = strings.TrimSpace(repo); repo != "" {
+			auth.AllowRepo(repo)
+		}
+	}
+	executor, err := NewExecutorFromEnv()
+	if err != nil {
+		slog.Error("Invalid remediation executor", "error", err)
+		os.Exit(1)
+	}
+	agents, err := NewAgentRegistryFromEnv()
+	if err != nil {
+		slog.Error("Invalid agent provider configuration", "error", err)
+		os.Exit(1)
+	}
+	logLines := 2000
+	if v := os.Getenv("REMEDIATION_LOG_LINES"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			logLines = parsed
+		}
+	}
+	remediationLogs := NewRemediationLogs(logLines)
+	contextLogs := 20
+	if v := os.Getenv("REMEDIATION_CONTEXT_LOGS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed >= 0 {
+			contextLogs = parsed
+		}
+	}
+	gitHosts, err := NewGitHostsFromEnv()
+	if err != nil {
+		slog.Error("Invalid git host configuration", "error", err)
+		os.Exit(1)
+	}
+	alerts, err := NewAlerterFromEnv()
+	if err != nil {
+		slog.Error("Invalid alert configuration", "error", err)
+		os.Exit(1)
+	}
+	remediation := NewRemediationService(remediationStore, remediationLogs, executor, agents, gitHosts)
 	wsHub := NewWSHub()
 
+	workers := 2
+	if v := os.Getenv("REMEDIATION_WORKERS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			workers = parsed
+		}
+	}
+
+	tracker := &FixTracker{
+		pollInterval: 2 * time.Minute,
+		verifyWindow: 30 * time.Minute,
+	}
+	if t := os.Getenv("PR_POLL_INTERVAL"); t != "" {
+		if parsed, err := time.ParseDuration(t); err == nil && parsed > 0 {
+			tracker.pollInterval = parsed
+		}
+	}
+	if t := os.Getenv("FIX_VERIFY_WINDOW"); t != "" {
+		if parsed, err := time.ParseDuration(t); err == nil {
+			tracker.verifyWindow = parsed
+		}
+	}
+
+	retention := JobRetention{Keep: 10, TTL: 24 * time.Hour}
+	if v := os.Getenv("REMEDIATION_KEEP_CONTAINERS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed >= 0 {
+			retention.Keep = parsed
+		}
+	}
+	if t := os.Getenv("REMEDIATION_CONTAINER_TTL"); t != "" {
+		if parsed, err := time.ParseDuration(t); err == nil && parsed >= 0 {
+			retention.TTL = parsed
+		}
+	}
+
 	app := &App{
 		store:            store,
 		remediation:      remediation,
 		remediationStore: remediationStore,
+		remediationLogs:  remediationLogs,
+		incidents:        incidents,
+		auth:             auth,
+		gitHosts:         gitHosts,
+		alerts:           alerts,
+		prober:           NewProber(),
+		policy:           NewPolicyEngine(),
+		queue:            NewRemediationQueue(workers),
+		tracker:          tracker,
+		jobRetention:     retention,
+		contextLogs:      contextLogs,
+		webhookSecret:    os.Getenv("GITHUB_WEBHOOK_SECRET"),
 		wsHub:            wsHub,
 	}
 
+	store.OnStatusChange(app.handleStatusChange)
+	store.OnStatusChange(app.alertStatusChange)
+	remediationLogs.OnAppend(app.broadcastRemediationLog)
+
 	// Setup routes
 	mux := http.NewServeMux()
 
 	// API endpoints
-	mux.HandleFunc("/api/heartbeat", app.HeartbeatHandler)
-	mux.HandleFunc("/api/services", app.ServicesHandler)
-	mux.HandleFunc("/api/services/", app.ServiceHandler)
+	mux.HandleFunc("/api/heartbeat", app.HeartbeatHandler) // checks ingest tokens itself
+	mux.HandleFunc("/api/services", app.requireAdmin(app.ServicesHandler))
+	mux.HandleFunc("/api/services/", app.requireAdmin(app.ServiceHandler))
 	mux.HandleFunc("/api/health", app.HealthHandler)
-	mux.HandleFunc("/api/remediations", app.RemediationsHandler)
-	mux.HandleFunc("/api/remediations/", app.RemediationDetailHandler)
+	mux.HandleFunc("/api/remediations", app.requireAdmin(app.RemediationsHandler))
+	mux.HandleFunc("/api/remediations/", app.requireAdmin(app.RemediationDetailHandler))
 	mux.HandleFunc("/api/remediation/report", app.RemediationReportHandler)
+	mux.HandleFunc("/api/remediation/progress", app.RemediationProgressHandler)
+	mux.HandleFunc("/api/webhooks/github", app.GitHubWebhookHandler) // verified by signature
+	mux.HandleFunc("/api/incidents", app.requireAdmin(app.IncidentsHandler))
+	mux.HandleFunc("/api/incidents/", app.requireAdmin(app.IncidentDetailHandler))
+	mux.HandleFunc("/api/tokens", app.requireAdminKey(app.requireAdmin(app.TokensHandler)))
+	mux.HandleFunc("/api/tokens/", app.requireAdminKey(app.requireAdmin(app.TokenHandler)))
+	mux.HandleFunc("/api/allowed-repos", app.requireAdminKey(app.requireAdmin(app.AllowedReposHandler)))
+	mux.HandleFunc("/api/alerts/test", app.requireAdmin(app.AlertTestHandler))
 
 	// Legacy endpoints (for backwards compatibility)
 	mux.HandleFunc("/heartbeat", app.HeartbeatHandler)
 	mux.HandleFunc("/health", app.HealthHandler)
 
 	// WebSocket endpoint
-	mux.Handle("/ws", app.WebSocketHandler())
+	mux.Handle("/ws", app.requireAdmin(app.WebSocketHandler().ServeHTTP))
 
 	// Serve static frontend files
 	staticDir := os.Getenv("STATIC_DIR")
@@ -110,13 +241,28 @@ func main() {
 		WriteTimeout: 10 * time.Second,
 	}
 
-	// Start timeout checker in background
+	// Start timeout checker, active prober, fix tracker, remediation workers
+	// and job reaper in background
 	ctx, cancel := context.WithCancel(context.Background())
+	// Settle remediations left running by the last run; if the executor
+	// cannot list its jobs yet the job reaper retries
+	var unreconciled []string
+	if interrupted := app.runningIDs(); !app.reconcileRemediations(ctx, interrupted) {
+		unreconciled = interrupted
+	}
 	go app.runTimeoutChecker(ctx)
+	go app.runProber(ctx)
+	go app.runFixTracker(ctx)
+	go app.runRemediationWorkers(ctx)
+	go app.runJobReaper(ctx, unreconciled)
 
 	// Start server in goroutine
 	go func() {
-		slog.Info("Server starting", "port", port, "heartbeat_timeout", timeout.String())
+		slog.Info("Server starting",
+			"port", port,
+			"heartbeat_timeout", timeout.String(),
+			"allow_unregistered_heartbeats", allowUnregistered,
+		)
 		if err := server.ListenAndServe(); err != http.ErrServerClosed {
 			slog.Error("Server error", "error", err)
 			os.Exit(1)
@@ -138,6 +284,7 @@ func main() {
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		slog.Error("Server shutdown error", "error", err)
 	}
+	store.FlushUptime()
 
 	slog.Info("Server stopped")
 }
@@ -189,56 +336,80 @@ func spaHandler(fs http.Handler, staticDir string) http.Handler {
 	})
 }
 
-// TriggerRemediation triggers the OpenCode remediation for a failed service
+// TriggerRemediation asks the policy engine whether to remediate a failed
+// service and queues a remediation if so
 func (app *App) TriggerRemediation(service *Service, errorLog string) {
-	// Check if a remediation is already in progress for this service
-	existingRemediations := app.remediationStore.GetByService(service.Name)
-	for _, r := range existingRemediations {
-		if r.Status == RemediationRunning || r.Status == RemediationPending {
-			slog.Info("Remediation already in progress for service, skipping duplicate trigger",
-				"service", service.Name,
-				"status", r.Status)
-			return
-		}
-	}
-
 	if service.GitHubRepo == "" {
 		slog.Warn("Cannot remediate service without GitHub repo", "service", service.Name)
 		return
 	}
 
+	if !app.auth.IsRepoAllowed(service.GitHubRepo) {
+		slog.Warn("Remediation blocked - repository is not allow-listed",
+			"service", service.Name,
+			"github_repo", service.GitHubRepo,
+		)
+		app.store.RecordPolicyDecision(service.Name, PolicyDecision{
+			Time:   time.Now(),
+			Action: PolicySkip,
+			Reason: "repository is not allow-listed",
+		})
+		return
+	}
+
+	decision := app.policy.Evaluate(service, errorLog, app.remediationStore.GetByService(service.Name), time.Now())
+	app.store.RecordPolicyDecision(service.Name, decision)
+	if decision.Action != PolicyTrigger {
+		slog.Info("Remediation skipped by policy",
+			"service", service.Name,
+			"reason", decision.Reason,
+		)
+		return
+	}
+	// The queued record marks the service as in progress from here on
+	defer app.policy.Finished(service.Name)
+
+	policy := effectivePolicy(service.RemediationPolicy)
+	app.startRemediation(RemediationRequest{
+		ID:          newRemediationID(),
+		ServiceName: service.Name,
+		GitHubRepo:  service.GitHubRepo,
+		RepoPath:    service.RepoPath,
+		BaseBranch:  service.BaseBranch,
+		ErrorLog:    errorLog,
+		Signature:   decision.Signature,
+		Mode:        decision.Mode,
+		Agent:       policy.Agent,
+		Priority:    policy.Priority,
+		TestPolicy:  policy.Tests,
+		Context:     buildRemediationContext(service, errorLog, app.contextLogs),
+		TriggeredBy: "policy",
+	})
+}
+
+// startRemediation logs a remediation on its service, links it to the ongoing
+// incident and queues it
+func (app *App) startRemediation(req RemediationRequest) *RemediationRecord {
 	slog.Info("Triggering remediation",
-		"service", service.Name,
-		"github_repo", service.GitHubRepo,
-		"error", errorLog,
+		"service", req.ServiceName,
+		"github_repo", req.GitHubRepo,
+		"mode", req.Mode,
+		"triggered_by", req.TriggeredBy,
+		"error", req.ErrorLog,
 	)
 
-	app.store.AddRemediationLog(service.Name,
-		time.Now().Format(time.RFC3339)+" - Remediation triggered: "+errorLog)
+	app.store.AddRemediationLog(req.ServiceName,
+		time.Now().Format(time.RFC3339)+" - Remediation triggered ("+req.TriggeredBy+"): "+req.ErrorLog)
 
 	// Broadcast update after adding remediation log
-	if updated, ok := app.store.GetService(service.Name); ok {
+	if updated, ok := app.store.GetService(req.ServiceName); ok {
 		app.BroadcastServiceUpdate(updated)
 	}
 
-	err := app.remediation.RunOpenCode(service.GitHubRepo, errorLog, service.Name)
-	if err != nil {
-		slog.Error("Remediation failed",
-			"service", service.Name,
-			"error", err,
-		)
-		app.store.AddRemediationLog(service.Name,
-			time.Now().Format(time.RFC3339)+" - Remediation failed: "+err.Error())
-	} else {
-		slog.Info("Remediation completed",
-			"service", service.Name,
-		)
-		app.store.AddRemediationLog(service.Name,
-			time.Now().Format(time.RFC3339)+" - Remediation completed successfully")
+	// Link the remediation to the service's ongoing incident
+	if incident, ok := app.incidents.LinkRemediation(req.ServiceName, req.ID); ok {
+		app.wsHub.Broadcast("incident_update", incident)
 	}
 
-	// Broadcast final update after remediation completes/fails
-	if updated, ok := app.store.GetService(service.Name); ok {
-		app.BroadcastServiceUpdate(updated)
-	}
+	return app.EnqueueRemediation(req)
 }
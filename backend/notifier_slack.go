@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// SlackNotifier posts the rendered text to a Slack incoming webhook. Anything
+// that accepts Slack's {"text": ...} payload works, e.g. Mattermost.
+type SlackNotifier struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewSlackNotifier creates a Slack-compatible webhook notifier
+func NewSlackNotifier(url string) *SlackNotifier {
+	return &SlackNotifier{
+		url:        url,
+		httpClient: &http.Client{Timeout: alertTimeout},
+	}
+}
+
+// slackEscaper escapes the characters Slack treats as control sequences, so
+// text from heartbeats cannot become <!channel> mentions or <url|links>
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func (n *SlackNotifier) Notify(ctx context.Context, msg AlertMessage) error {
+	body, err := json.Marshal(map[string]string{"text": slackEscaper.Replace(msg.Text)})
+	if err != nil {
+		return err
+	}
+	return postAlert(ctx, n.httpClient, n.url, body, func(*http.Request) {})
+}
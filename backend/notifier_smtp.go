@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPNotifier emails alerts as plain text. STARTTLS is used when the server
+// offers it; PLAIN auth needs it unless the server is on localhost.
+type SMTPNotifier struct {
+	addr     string // host:port
+	from     string
+	to       []string
+	username string
+	password string
+}
+
+// NewSMTPNotifier creates an email notifier
+func NewSMTPNotifier(addr, from string, to []string, username, password string) *SMTPNotifier {
+	return &SMTPNotifier{
+		addr:     addr,
+		from:     from,
+		to:       to,
+		username: username,
+		password: password,
+	}
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, msg AlertMessage) error {
+	host, _, err := net.SplitHostPort(n.addr)
+	if err != nil {
+		return fmt.Errorf("invalid smtp_addr: %w", err)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("starttls: %w", err)
+		}
+	}
+	if n.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.username, n.password, host)); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	}
+
+	// The envelope takes bare addresses; "Name <addr>" is for the headers
+	from, err := mail.ParseAddress(n.from)
+	if err != nil {
+		return err
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, to := range n.to {
+		rcpt, err := mail.ParseAddress(to)
+		if err != nil {
+			return err
+		}
+		if err := client.Rcpt(rcpt.Address); err != nil {
+			return fmt.Errorf("rcpt %s: %w", rcpt.Address, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(n.buildMessage(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildMessage renders the email with CRLF line endings
+func (n *SMTPNotifier) buildMessage(msg AlertMessage) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", n.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	fmt.Fprintf(&b, "X-Highline-Event: %s\r\n", msg.Event)
+	b.WriteString("\r\n")
+	text := strings.ReplaceAll(msg.Text, "\r\n", "\n")
+	b.WriteString(strings.ReplaceAll(text, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// WebhookNotifier POSTs alerts as JSON: the alert's fields plus the rendered
+// "subject" and "text". With a secret the body is signed like agent
+// callbacks, as HMAC-SHA256 over "<timestamp>.<body>".
+type WebhookNotifier struct {
+	url        string
+	headers    map[string]string
+	secret     string
+	httpClient *http.Client
+}
+
+// NewWebhookNotifier creates a generic webhook notifier
+func NewWebhookNotifier(target string, headers map[string]string, secret string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:        target,
+		headers:    headers,
+		secret:     secret,
+		httpClient: &http.Client{Timeout: alertTimeout},
+	}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, msg AlertMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return postAlert(ctx, n.httpClient, n.url, body, func(req *http.Request) {
+		for name, value := range n.headers {
+			req.Header.Set(name, value)
+		}
+		if n.secret != "" {
+			timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+			req.Header.Set(headerTimestamp, timestamp)
+			req.Header.Set(headerSignature, signCallback(n.secret, timestamp, body))
+		}
+	})
+}
+
+// postAlert sends a JSON body and fails on a non-2xx response
+func postAlert(ctx context.Context, client *http.Client, target string, body []byte, header func(*http.Request)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Highline-Alerts")
+	header(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// The URL may be a secret, e.g. a Slack webhook
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("post failed: %w", urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %d: %s", resp.StatusCode, bytes.TrimSpace(detail))
+	}
+	return nil
+}
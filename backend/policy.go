@@ -0,0 +1,341 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RemediationMode controls whether Highline attempts fixes for a service
+type RemediationMode string
+
+const (
+	RemediationModeAuto    RemediationMode = "auto"    // fix and push a branch (default)
+	RemediationModeSuggest RemediationMode = "suggest" // fix and report, but never push
+	RemediationModeOff     RemediationMode = "off"
+)
+
+// RemediationPolicy declares when and how a service should be remediated.
+// Zero values fall back to defaultRemediationPolicy.
+type RemediationPolicy struct {
+	Mode              RemediationMode `json:"mode,omitempty"`
+	Agent             *AgentSelection `json:"agent,omitempty"`                // default agent provider when nil
+	MinErrors         int             `json:"min_errors,omitempty"`           // errors within error_window before triggering
+	ErrorWindow       Duration        `json:"error_window,omitempty"`         // window for min_errors
+	Cooldown          Duration        `json:"cooldown,omitempty"`             // wait after a failed attempt
+	MaxAttemptsPerDay int             `json:"max_attempts_per_day,omitempty"` // attempts in any 24h
+	DedupeWindow      Duration        `json:"dedupe_window,omitempty"`        // ignore a repeated error signature for this long
+	Priority          int             `json:"priority,omitempty"`             // queue priority; higher runs first
+	Tests             *TestPolicy     `json:"tests,omitempty"`                // run the repo's tests before pushing
+}
+
+// TestFailureAction decides what happens to a fix whose tests fail
+type TestFailureAction string
+
+const (
+	TestFailureBlock TestFailureAction = "block" // don't push (default)
+	TestFailurePush  TestFailureAction = "push"  // push and label the PR "tests failing"
+)
+
+// TestPolicy runs the service's test command on the agent's fix before it is pushed
+type TestPolicy struct {
+	Command   string            `json:"command"`              // e.g. "go test ./..."
+	OnFailure TestFailureAction `json:"on_failure,omitempty"` // block (default) or push
+	Timeout   Duration          `json:"timeout,omitempty"`    // default 5m
+}
+
+// defaultTestTimeout bounds a test run when the policy sets no timeout
+const defaultTestTimeout = 5 * time.Minute
+
+// Validate checks the test policy
+func (t *TestPolicy) Validate() error {
+	if strings.TrimSpace(t.Command) == "" {
+		return fmt.Errorf("remediation_policy.tests.command is required")
+	}
+	switch t.OnFailure {
+	case "", TestFailureBlock, TestFailurePush:
+	default:
+		return fmt.Errorf("remediation_policy.tests.on_failure must be %q or %q", TestFailureBlock, TestFailurePush)
+	}
+	if t.Timeout < 0 {
+		return fmt.Errorf("remediation_policy.tests.timeout must not be negative")
+	}
+	return nil
+}
+
+// defaultRemediationPolicy applies to services without a declared policy
+var defaultRemediationPolicy = RemediationPolicy{
+	Mode:              RemediationModeAuto,
+	MinErrors:         1,
+	ErrorWindow:       Duration(5 * time.Minute),
+	Cooldown:          Duration(15 * time.Minute),
+	MaxAttemptsPerDay: 5,
+	DedupeWindow:      Duration(24 * time.Hour),
+}
+
+// Validate checks that the policy is usable
+func (p *RemediationPolicy) Validate() error {
+	switch p.Mode {
+	case "", RemediationModeAuto, RemediationModeSuggest, RemediationModeOff:
+	default:
+		return fmt.Errorf("remediation_policy.mode must be %q, %q or %q",
+			RemediationModeAuto, RemediationModeSuggest, RemediationModeOff)
+	}
+	if p.MinErrors < 0 || p.MaxAttemptsPerDay < 0 {
+		return fmt.Errorf("remediation_policy counts must not be negative")
+	}
+	if p.ErrorWindow < 0 || p.Cooldown < 0 || p.DedupeWindow < 0 {
+		return fmt.Errorf("remediation_policy durations must not be negative")
+	}
+	if p.Tests != nil {
+		return p.Tests.Validate()
+	}
+	return nil
+}
+
+// effectivePolicy returns the service's policy with defaults filled in
+func effectivePolicy(p *RemediationPolicy) RemediationPolicy {
+	policy := defaultRemediationPolicy
+	if p == nil {
+		return policy
+	}
+	if p.Mode != "" {
+		policy.Mode = p.Mode
+	}
+	policy.Agent = p.Agent
+	policy.Priority = p.Priority
+	policy.Tests = p.Tests
+	if p.MinErrors > 0 {
+		policy.MinErrors = p.MinErrors
+	}
+	if p.ErrorWindow > 0 {
+		policy.ErrorWindow = p.ErrorWindow
+	}
+	if p.Cooldown > 0 {
+		policy.Cooldown = p.Cooldown
+	}
+	if p.MaxAttemptsPerDay > 0 {
+		policy.MaxAttemptsPerDay = p.MaxAttemptsPerDay
+	}
+	if p.DedupeWindow > 0 {
+		policy.DedupeWindow = p.DedupeWindow
+	}
+	return policy
+}
+
+var (
+	signatureUUID   = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	signatureHex    = regexp.MustCompile(`\b0x[0-9a-fA-F]+\b|\b[0-9a-fA-F]{8,}\b`)
+	signatureNumber = regexp.MustCompile(`\d+`)
+	signatureSpace  = regexp.MustCompile(`\s+`)
+)
+
+// errorSignature fingerprints an error so repeats of the same failure can be
+// recognized. IDs, addresses, numbers and timestamps are masked and only the
+// first lines (message and top frames) are considered.
+func errorSignature(errorLog string) string {
+	var lines []string
+	for _, line := range strings.Split(errorLog, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+		if len(lines) == 5 {
+			break
+		}
+	}
+
+	normalized := strings.ToLower(strings.Join(lines, "\n"))
+	normalized = signatureUUID.ReplaceAllString(normalized, "<id>")
+	normalized = signatureHex.ReplaceAllString(normalized, "<hex>")
+	normalized = signatureNumber.ReplaceAllString(normalized, "#")
+	normalized = signatureSpace.ReplaceAllString(normalized, " ")
+
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:8])
+}
+
+// PolicyAction is the outcome of a policy evaluation
+type PolicyAction string
+
+const (
+	PolicyTrigger PolicyAction = "trigger"
+	PolicySkip    PolicyAction = "skip"
+)
+
+// PolicyDecision records why a remediation was or wasn't started
+type PolicyDecision struct {
+	Time      time.Time       `json:"time"`
+	Action    PolicyAction    `json:"action"`
+	Reason    string          `json:"reason"`
+	Mode      RemediationMode `json:"mode,omitempty"`
+	Signature string          `json:"signature,omitempty"`
+}
+
+// PolicyEngine decides whether an error should start a remediation
+type PolicyEngine struct {
+	mu     sync.Mutex
+	errors map[string][]time.Time // recent error times per service
+	active map[string]bool        // services with a remediation being started
+}
+
+// NewPolicyEngine creates a policy engine
+func NewPolicyEngine() *PolicyEngine {
+	return &PolicyEngine{
+		errors: make(map[string][]time.Time),
+		active: make(map[string]bool),
+	}
+}
+
+// Evaluate records an error for the service and decides whether to remediate.
+// history is the service's remediations, newest first. A trigger marks the
+// service active until Finished is called.
+func (e *PolicyEngine) Evaluate(service *Service, errorLog string, history []RemediationRecord, now time.Time) PolicyDecision {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	policy := effectivePolicy(service.RemediationPolicy)
+	decision := PolicyDecision{
+		Time:      now,
+		Action:    PolicySkip,
+		Mode:      policy.Mode,
+		Signature: errorSignature(errorLog),
+	}
+
+	if policy.Mode == RemediationModeOff {
+		decision.Reason = "remediation disabled by policy"
+		return decision
+	}
+
+	// Count errors inside the window
+	cutoff := now.Add(-time.Duration(policy.ErrorWindow))
+	recent := []time.Time{now}
+	for _, t := range e.errors[service.Name] {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	e.errors[service.Name] = recent
+	if len(recent) < policy.MinErrors {
+		decision.Reason = fmt.Sprintf("waiting for %d errors within %s",
+			policy.MinErrors, formatWindow(time.Duration(policy.ErrorWindow)))
+		return decision
+	}
+
+	if e.active[service.Name] {
+		decision.Reason = "remediation already in progress"
+		return decision
+	}
+
+	attempts := 0
+	var lastFailed *RemediationRecord
+	for i := range history {
+		record := &history[i]
+		if record.Status == RemediationRunning || record.Status == RemediationPending {
+			decision.Reason = "remediation " + record.ID + " already in progress"
+			return decision
+		}
+		// Only a fix counts as remediated; failed attempts go through the
+		// cooldown and the daily limit
+		if record.ErrorSignature == decision.Signature && record.producedFix() &&
+			now.Sub(record.StartTime) < time.Duration(policy.DedupeWindow) {
+			decision.Reason = "same error already remediated by " + record.ID
+			return decision
+		}
+		if now.Sub(record.StartTime) < 24*time.Hour {
+			attempts++
+		}
+		if lastFailed == nil && record.EndTime != nil && record.Status != RemediationCancelled && !record.producedFix() {
+			lastFailed = record
+		}
+	}
+
+	if lastFailed != nil {
+		until := lastFailed.EndTime.Add(time.Duration(policy.Cooldown))
+		if now.Before(until) {
+			decision.Reason = fmt.Sprintf("cooling down after failed remediation %s until %s",
+				lastFailed.ID, until.Format(time.RFC3339))
+			return decision
+		}
+	}
+
+	if attempts >= policy.MaxAttemptsPerDay {
+		decision.Reason = fmt.Sprintf("daily limit of %d attempts reached", policy.MaxAttemptsPerDay)
+		return decision
+	}
+
+	delete(e.errors, service.Name)
+	e.active[service.Name] = true
+	decision.Action = PolicyTrigger
+	decision.Reason = fmt.Sprintf("%d error(s) within %s", len(recent), formatWindow(time.Duration(policy.ErrorWindow)))
+	return decision
+}
+
+// producedFix reports whether a remediation succeeded with a fix: the agent
+// reported success or pushed a branch, or the merged fix was verified. A fix
+// whose verification found no effect or a regression does not count.
+func (r *RemediationRecord) producedFix() bool {
+	switch r.Verification {
+	case VerificationFixed:
+		return true
+	case VerificationNoEffect, VerificationRegressed:
+		return false
+	}
+	return r.Status == RemediationSuccess && r.AgentReport != nil && (r.AgentReport.Success || r.AgentReport.Pushed)
+}
+
+// Reserve marks a service active for a remediation started outside the
+// policy (manual or retry). Returns false if one is already being started.
+func (e *PolicyEngine) Reserve(serviceName string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.active[serviceName] {
+		return false
+	}
+	e.active[serviceName] = true
+	return true
+}
+
+// Finished clears the active mark set by a triggering Evaluate or Reserve
+func (e *PolicyEngine) Finished(serviceName string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	delete(e.active, serviceName)
+}
+
+// RecordPolicyDecision stores the latest decision on a service. It is added to
+// the remediation log unless it repeats the previous skip reason.
+func (s *ServiceStore) RecordPolicyDecision(serviceName string, decision PolicyDecision) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	service, exists := s.services[serviceName]
+	if !exists {
+		return
+	}
+
+	previous := service.PolicyDecision
+	service.PolicyDecision = &decision
+	if decision.Action == PolicySkip && previous != nil &&
+		previous.Action == PolicySkip && previous.Reason == decision.Reason {
+		s.persist(service)
+		return
+	}
+
+	message := decision.Time.Format(time.RFC3339) + " - Policy " + string(decision.Action) + ": " + decision.Reason
+	service.RemediationLog = append(service.RemediationLog, message)
+	if len(service.RemediationLog) > 10 {
+		service.RemediationLog = service.RemediationLog[len(service.RemediationLog)-10:]
+	}
+	service.addLog(LogEntry{
+		Timestamp: decision.Time,
+		Type:      "remediation",
+		Message:   message,
+	})
+	s.persist(service)
+}
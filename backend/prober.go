@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProbeType is the kind of active check performed against a target
+type ProbeType string
+
+const (
+	ProbeHTTP ProbeType = "http"
+	ProbeTCP  ProbeType = "tcp"
+	ProbeDNS  ProbeType = "dns"
+)
+
+const (
+	defaultProbeInterval = 30 * time.Second
+	defaultProbeTimeout  = 10 * time.Second
+	// maxProbeBodyBytes caps how much of an HTTP response is read for body matching
+	maxProbeBodyBytes = 1 << 20
+)
+
+// ProbeConfig describes an active check for a service that does not send heartbeats
+type ProbeConfig struct {
+	Type           ProbeType `json:"type"`
+	Target         string    `json:"target"`                    // URL (http), host:port (tcp) or hostname (dns)
+	Interval       Duration  `json:"interval,omitempty"`        // default 30s
+	Timeout        Duration  `json:"timeout,omitempty"`         // default 10s
+	ExpectedStatus int       `json:"expected_status,omitempty"` // http only, default any 2xx
+	BodyContains   string    `json:"body_contains,omitempty"`   // http only
+}
+
+// Validate checks that the probe can be run
+func (c *ProbeConfig) Validate() error {
+	if c.Interval < 0 || c.Timeout < 0 {
+		return fmt.Errorf("probe.interval and probe.timeout must not be negative")
+	}
+
+	switch c.Type {
+	case ProbeHTTP:
+		u, err := url.Parse(c.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("probe.target must be an http(s) URL")
+		}
+	case ProbeTCP:
+		if _, _, err := net.SplitHostPort(c.Target); err != nil {
+			return fmt.Errorf("probe.target must be host:port")
+		}
+	case ProbeDNS:
+		if c.Target == "" {
+			return fmt.Errorf("probe.target must be a hostname")
+		}
+	default:
+		return fmt.Errorf("probe.type must be %q, %q or %q", ProbeHTTP, ProbeTCP, ProbeDNS)
+	}
+	return nil
+}
+
+// interval returns the configured interval or the default
+func (c *ProbeConfig) interval() time.Duration {
+	if c.Interval > 0 {
+		return time.Duration(c.Interval)
+	}
+	return defaultProbeInterval
+}
+
+// timeout returns the configured timeout or the default
+func (c *ProbeConfig) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return time.Duration(c.Timeout)
+	}
+	return defaultProbeTimeout
+}
+
+// ProbeResult is the outcome of a single probe
+type ProbeResult struct {
+	Healthy bool
+	Latency time.Duration
+	Error   string
+	Details map[string]interface{}
+}
+
+// Prober runs HTTP, TCP and DNS checks
+type Prober struct {
+	client   *http.Client
+	dialer   *net.Dialer
+	resolver *net.Resolver
+}
+
+// NewProber creates a prober using the default network stack
+func NewProber() *Prober {
+	return &Prober{
+		client: &http.Client{
+			// Don't follow redirects so expected_status can match 3xx responses
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		dialer:   &net.Dialer{},
+		resolver: net.DefaultResolver,
+	}
+}
+
+// Probe runs a single check against the configured target
+func (p *Prober) Probe(ctx context.Context, cfg ProbeConfig) ProbeResult {
+	ctx, cancel := context.WithTimeout(ctx, cfg.timeout())
+	defer cancel()
+
+	start := time.Now()
+	var result ProbeResult
+	switch cfg.Type {
+	case ProbeHTTP:
+		result = p.probeHTTP(ctx, cfg)
+	case ProbeTCP:
+		result = p.probeTCP(ctx, cfg)
+	case ProbeDNS:
+		result = p.probeDNS(ctx, cfg)
+	default:
+		result = ProbeResult{Error: fmt.Sprintf("unknown probe type %q", cfg.Type)}
+	}
+	result.Latency = time.Since(start)
+
+	if result.Details == nil {
+		result.Details = make(map[string]interface{})
+	}
+	result.Details["probe_type"] = string(cfg.Type)
+	result.Details["target"] = cfg.Target
+	result.Details["latency_ms"] = result.Latency.Milliseconds()
+	return result
+}
+
+// probeHTTP performs a GET and checks the status code and body
+func (p *Prober) probeHTTP(ctx context.Context, cfg ProbeConfig) ProbeResult {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.Target, nil)
+	if err != nil {
+		return ProbeResult{Error: fmt.Sprintf("invalid request: %v", err)}
+	}
+	req.Header.Set("User-Agent", "Highline-Prober/1.0")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return ProbeResult{Error: fmt.Sprintf("GET %s failed: %v", cfg.Target, err)}
+	}
+	defer resp.Body.Close()
+
+	details := map[string]interface{}{"status_code": resp.StatusCode}
+
+	if cfg.ExpectedStatus != 0 && resp.StatusCode != cfg.ExpectedStatus {
+		return ProbeResult{
+			Error:   fmt.Sprintf("GET %s returned %d, expected %d", cfg.Target, resp.StatusCode, cfg.ExpectedStatus),
+			Details: details,
+		}
+	}
+	if cfg.ExpectedStatus == 0 && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+		return ProbeResult{
+			Error:   fmt.Sprintf("GET %s returned %d", cfg.Target, resp.StatusCode),
+			Details: details,
+		}
+	}
+
+	if cfg.BodyContains != "" {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeBodyBytes))
+		if err != nil {
+			return ProbeResult{Error: fmt.Sprintf("failed to read body: %v", err), Details: details}
+		}
+		if !strings.Contains(string(body), cfg.BodyContains) {
+			return ProbeResult{
+				Error:   fmt.Sprintf("GET %s response body does not contain %q", cfg.Target, cfg.BodyContains),
+				Details: details,
+			}
+		}
+	}
+
+	return ProbeResult{Healthy: true, Details: details}
+}
+
+// probeTCP checks that a TCP connection can be established
+func (p *Prober) probeTCP(ctx context.Context, cfg ProbeConfig) ProbeResult {
+	conn, err := p.dialer.DialContext(ctx, "tcp", cfg.Target)
+	if err != nil {
+		return ProbeResult{Error: fmt.Sprintf("TCP connect to %s failed: %v", cfg.Target, err)}
+	}
+	conn.Close()
+	return ProbeResult{Healthy: true}
+}
+
+// probeDNS checks that the hostname resolves to at least one address
+func (p *Prober) probeDNS(ctx context.Context, cfg ProbeConfig) ProbeResult {
+	addrs, err := p.resolver.LookupHost(ctx, cfg.Target)
+	if err != nil {
+		return ProbeResult{Error: fmt.Sprintf("DNS lookup of %s failed: %v", cfg.Target, err)}
+	}
+	if len(addrs) == 0 {
+		return ProbeResult{Error: fmt.Sprintf("DNS lookup of %s returned no addresses", cfg.Target)}
+	}
+	return ProbeResult{Healthy: true, Details: map[string]interface{}{"addresses": addrs}}
+}
+
+// runProber periodically probes every service that has a probe configured
+func (app *App) runProber(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var mu sync.Mutex
+	lastRun := make(map[string]time.Time)
+	inFlight := make(map[string]bool)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, service := range app.store.GetAllServices() {
+				if service.Probe == nil {
+					continue
+				}
+				cfg := *service.Probe
+
+				mu.Lock()
+				due := !inFlight[service.Name] && now.Sub(lastRun[service.Name]) >= cfg.interval()
+				if due {
+					inFlight[service.Name] = true
+					lastRun[service.Name] = now
+				}
+				mu.Unlock()
+				if !due {
+					continue
+				}
+
+				go func(name string) {
+					defer func() {
+						mu.Lock()
+						delete(inFlight, name)
+						mu.Unlock()
+					}()
+					app.runProbe(ctx, name, cfg)
+				}(service.Name)
+			}
+		}
+	}
+}
+
+// runProbe probes a single service and feeds the result in as a heartbeat
+func (app *App) runProbe(ctx context.Context, serviceName string, cfg ProbeConfig) {
+	result := app.prober.Probe(ctx, cfg)
+	if ctx.Err() != nil {
+		return
+	}
+
+	req := HeartbeatRequest{
+		ServiceName: serviceName,
+		Status:      string(StatusHealthy),
+		LogData: &LogData{
+			EventType: "probe",
+			Details:   result.Details,
+		},
+	}
+	if !result.Healthy {
+		req.Status = string(StatusError)
+		req.ErrorLog = result.Error
+	}
+
+	if _, err := app.processHeartbeat(req); err != nil {
+		slog.Warn("Failed to record probe result", "service", serviceName, "error", err)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProbeHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			w.Write([]byte(`{"status": "ok"}`))
+		case "/moved":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		default:
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		cfg     ProbeConfig
+		healthy bool
+		errPart string
+	}{
+		{"any 2xx", ProbeConfig{Target: server.URL + "/ok"}, true, ""},
+		{"5xx", ProbeConfig{Target: server.URL + "/fail"}, false, "returned 500"},
+		{"expected status", ProbeConfig{Target: server.URL + "/fail", ExpectedStatus: 500}, true, ""},
+		{"unexpected status", ProbeConfig{Target: server.URL + "/ok", ExpectedStatus: 204}, false, "returned 200, expected 204"},
+		{"redirect not followed", ProbeConfig{Target: server.URL + "/moved", ExpectedStatus: 302}, true, ""},
+		{"body match", ProbeConfig{Target: server.URL + "/ok", BodyContains: `"ok"`}, true, ""},
+		{"body mismatch", ProbeConfig{Target: server.URL + "/ok", BodyContains: "healthy"}, false, "does not contain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Type = ProbeHTTP
+			result := NewProber().Probe(context.Background(), tt.cfg)
+			if result.Healthy != tt.healthy {
+				t.Fatalf("healthy = %v, want %v (error %q)", result.Healthy, tt.healthy, result.Error)
+			}
+			if !strings.Contains(result.Error, tt.errPart) {
+				t.Errorf("error = %q, want it to contain %q", result.Error, tt.errPart)
+			}
+			if result.Details["target"] != tt.cfg.Target || result.Details["status_code"] == nil {
+				t.Errorf("details = %v", result.Details)
+			}
+		})
+	}
+}
+
+func TestProbeHTTPTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	cfg := ProbeConfig{Type: ProbeHTTP, Target: server.URL, Timeout: Duration(100 * time.Millisecond)}
+	result := NewProber().Probe(context.Background(), cfg)
+	if result.Healthy {
+		t.Fatal("probe of a hanging server succeeded")
+	}
+	if result.Latency > 5*time.Second {
+		t.Errorf("probe took %s, want it cut at the timeout", result.Latency)
+	}
+}
+
+func TestProbeTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	open := listener.Addr().String()
+
+	result := NewProber().Probe(context.Background(), ProbeConfig{Type: ProbeTCP, Target: open})
+	if !result.Healthy {
+		t.Fatalf("probe of listening port failed: %s", result.Error)
+	}
+
+	listener.Close()
+	result = NewProber().Probe(context.Background(), ProbeConfig{Type: ProbeTCP, Target: open})
+	if result.Healthy || !strings.Contains(result.Error, "TCP connect") {
+		t.Fatalf("probe of closed port: healthy = %v, error %q", result.Healthy, result.Error)
+	}
+}
+
+func TestProbeDNS(t *testing.T) {
+	prober := NewProber()
+
+	result := prober.Probe(context.Background(), ProbeConfig{Type: ProbeDNS, Target: "localhost"})
+	if !result.Healthy {
+		t.Fatalf("lookup of localhost failed: %s", result.Error)
+	}
+	if addrs, _ := result.Details["addresses"].([]string); len(addrs) == 0 {
+		t.Errorf("details = %v, want addresses", result.Details)
+	}
+
+	// .invalid never resolves (RFC 6761)
+	result = prober.Probe(context.Background(), ProbeConfig{Type: ProbeDNS, Target: "highline.invalid"})
+	if result.Healthy || !strings.Contains(result.Error, "DNS lookup") {
+		t.Fatalf("lookup of .invalid: healthy = %v, error %q", result.Healthy, result.Error)
+	}
+}
+
+func TestProbeDNSTimeout(t *testing.T) {
+	prober := NewProber()
+	prober.resolver = &net.Resolver{
+		PreferGo: true,
+		// A name server that never answers
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+
+	cfg := ProbeConfig{Type: ProbeDNS, Target: "example.com", Timeout: Duration(100 * time.Millisecond)}
+	result := prober.Probe(context.Background(), cfg)
+	if result.Healthy {
+		t.Fatal("lookup through a silent name server succeeded")
+	}
+	if result.Latency > 5*time.Second {
+		t.Errorf("lookup took %s, want it cut at the timeout", result.Latency)
+	}
+}
+
+func TestProbeConfigValidate(t *testing.T) {
+	tests := []struct {
+		cfg   ProbeConfig
+		valid bool
+	}{
+		{ProbeConfig{Type: ProbeHTTP, Target: "https://example.com/health"}, true},
+		{ProbeConfig{Type: ProbeHTTP, Target: "example.com"}, false},
+		{ProbeConfig{Type: ProbeTCP, Target: "db:5432"}, true},
+		{ProbeConfig{Type: ProbeTCP, Target: "db"}, false},
+		{ProbeConfig{Type: ProbeDNS, Target: "example.com"}, true},
+		{ProbeConfig{Type: ProbeDNS}, false},
+		{ProbeConfig{Type: "icmp", Target: "example.com"}, false},
+		{ProbeConfig{Type: ProbeDNS, Target: "example.com", Timeout: Duration(-time.Second)}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(); (err == nil) != tt.valid {
+			t.Errorf("Validate(%+v) = %v, want valid %v", tt.cfg, err, tt.valid)
+		}
+	}
+}
+
+func TestProbeLogMessageLatency(t *testing.T) {
+	data := LogData{EventType: "probe", Details: map[string]interface{}{
+		"probe_type": string(ProbeHTTP),
+		"target":     "https://example.com",
+		"latency_ms": int64(42),
+	}}
+	want := "HTTP probe of https://example.com succeeded (42ms)"
+	if got := buildLogMessage(&data); got != want {
+		t.Errorf("message = %q, want %q", got, want)
+	}
+
+	// Details read back from storage hold JSON numbers
+	encoded, _ := json.Marshal(data)
+	var decoded LogData
+	json.Unmarshal(encoded, &decoded)
+	if got := buildLogMessage(&decoded); got != want {
+		t.Errorf("message after JSON = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// maxPRErrorLogBytes caps how much of the error log is quoted in a PR body
+const maxPRErrorLogBytes = 8 * 1024
+
+// testsFailingLabel marks PRs pushed even though the service's tests failed
+const testsFailingLabel = "tests failing"
+
+// fixBranchName returns the branch the agent pushes a fix to
+func fixBranchName(remediationID string) string {
+	return "highline-fix-" + remediationID
+}
+
+// buildPullRequestBody renders the PR description for a remediation
+func buildPullRequestBody(record *RemediationRecord) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Automated fix proposed by Highline for **%s** (remediation `%s`).\n\n", record.ServiceName, record.ID)
+	if record.RepoPath != "" {
+		fmt.Fprintf(&b, "Changes are limited to `%s/`.\n\n", record.RepoPath)
+	}
+
+	b.WriteString("### Error\n\n```\n")
+	errorLog := record.ErrorLog
+	if len(errorLog) > maxPRErrorLogBytes {
+		errorLog = errorLog[:maxPRErrorLogBytes] + "\n... (truncated)"
+	}
+	b.WriteString(strings.ReplaceAll(errorLog, "```", "` ` `"))
+	b.WriteString("\n```\n\n")
+
+	if report := record.AgentReport; report != nil {
+		if report.Summary != "" {
+			b.WriteString("### Agent summary\n\n")
+			b.WriteString(report.Summary)
+			b.WriteString("\n\n")
+		}
+
+		if len(report.FilesChanged) > 0 {
+			b.WriteString("### Files changed\n\n")
+			for _, file := range report.FilesChanged {
+				fmt.Fprintf(&b, "- `%s`\n", file)
+			}
+			b.WriteString("\n")
+		}
+
+		if tests := report.Tests; tests != nil {
+			fmt.Fprintf(&b, "### Tests: %s\n\n", tests.Result)
+			if tests.Command != "" {
+				fmt.Fprintf(&b, "`%s` exited with code %d.\n\n", tests.Command, tests.ExitCode)
+			}
+			if tests.Result == TestsFailed && tests.Output != "" {
+				b.WriteString("<details><summary>Test output (tail)</summary>\n\n```\n")
+				b.WriteString(strings.ReplaceAll(tests.Output, "```", "` ` `"))
+				b.WriteString("\n```\n</details>\n\n")
+			}
+		}
+
+		if report.CommitHash != "" {
+			fmt.Fprintf(&b, "Commit: %s\n\n", report.CommitHash)
+		}
+	}
+
+	b.WriteString("---\n_Please review carefully before merging._\n")
+	return b.String()
+}
+
+// openPullRequest opens a PR for a remediation whose agent pushed a fix branch
+func (app *App) openPullRequest(remediationID string) {
+	record, exists := app.remediationStore.Get(remediationID)
+	if !exists || record.AgentReport == nil {
+		return
+	}
+
+	// The agent rejects out-of-scope changes itself; a report that still
+	// lists some does not get a pull request
+	if outside := outOfScope(record.RepoPath, record.AgentReport.FilesChanged); len(outside) > 0 {
+		app.failPullRequest(record, fmt.Errorf("fix changes files outside %s: %s", record.RepoPath, strings.Join(outside, ", ")))
+		return
+	}
+
+	provider, repo, err := app.gitHosts.Provider(record.GitHubRepo)
+	if err != nil {
+		app.failPullRequest(record, err)
+		return
+	}
+	if provider == nil {
+		slog.Info("[REMEDIATION] Git host has no pull request API - fix branch pushed only", "id", remediationID)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	base := record.BaseBranch
+	if base == "" {
+		base, err = provider.GetDefaultBranch(ctx, repo)
+		if err != nil {
+			app.failPullRequest(record, fmt.Errorf("failed to get default branch: %w", err))
+			return
+		}
+	}
+
+	pr, err := provider.CreatePullRequest(ctx, repo, NewPullRequest{
+		Title: fmt.Sprintf("fix(%s): automated remediation %s", record.ServiceName, record.ID),
+		Head:  fixBranchName(record.ID),
+		Base:  base,
+		Body:  buildPullRequestBody(record),
+	})
+	if err != nil {
+		app.failPullRequest(record, fmt.Errorf("failed to create pull request: %w", err))
+		return
+	}
+
+	slog.Info("[REMEDIATION] Pull request opened",
+		"id", record.ID,
+		"service", record.ServiceName,
+		"pr_number", pr.Number,
+		"pr_url", pr.HTMLURL,
+	)
+
+	if tests := record.AgentReport.Tests; tests != nil && tests.Result == TestsFailed {
+		if err := provider.AddLabels(ctx, repo, pr.Number, []string{testsFailingLabel}); err != nil {
+			slog.Warn("[REMEDIATION] Failed to label pull request",
+				"id", record.ID,
+				"pr_number", pr.Number,
+				"error", err,
+			)
+		}
+	}
+
+	app.remediationStore.SetPullRequest(record.ID, pr.Number, pr.HTMLURL, "")
+	app.store.AddRemediationLog(record.ServiceName,
+		time.Now().Format(time.RFC3339)+" - Pull request opened: "+pr.HTMLURL)
+
+	app.broadcastRemediation(record.ID)
+}
+
+// failPullRequest records a failed attempt to open a pull request
+func (app *App) failPullRequest(record *RemediationRecord, err error) {
+	slog.Error("[REMEDIATION] Failed to open pull request",
+		"id", record.ID,
+		"service", record.ServiceName,
+		"error", err,
+	)
+
+	app.remediationStore.SetPullRequest(record.ID, 0, "", err.Error())
+	app.broadcastRemediation(record.ID)
+}
+
+// broadcastRemediation sends the current state of a remediation to all clients
+func (app *App) broadcastRemediation(remediationID string) {
+	if record, exists := app.remediationStore.Get(remediationID); exists {
+		app.wsHub.Broadcast("remediation_update", record)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// RemediationQueue runs queued remediations on a bounded pool of workers.
+// The queue itself is the set of pending records in the RemediationStore, so
+// it survives restarts.
+type RemediationQueue struct {
+	workers int
+	wake    chan struct{}
+}
+
+// NewRemediationQueue creates a queue served by the given number of workers
+func NewRemediationQueue(workers int) *RemediationQueue {
+	if workers < 1 {
+		workers = 1
+	}
+	return &RemediationQueue{
+		workers: workers,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// notify wakes an idle worker
+func (q *RemediationQueue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// EnqueueRemediation adds a remediation to the queue
+func (app *App) EnqueueRemediation(req RemediationRequest) *RemediationRecord {
+	record := app.remediationStore.Create(req)
+
+	slog.Info("[REMEDIATION] Queued",
+		"id", record.ID,
+		"service", record.ServiceName,
+		"priority", record.Priority,
+	)
+
+	app.broadcastQueue()
+	app.queue.notify()
+	return record
+}
+
+// broadcastQueue sends every queued remediation so clients see new positions
+func (app *App) broadcastQueue() {
+	for _, record := range app.remediationStore.Queue() {
+		app.wsHub.Broadcast("remediation_update", record)
+	}
+}
+
+// runRemediationWorkers starts the worker pool and blocks until ctx is done
+func (app *App) runRemediationWorkers(ctx context.Context) {
+	slog.Info("Remediation workers started",
+		"workers", app.queue.workers,
+		"queued", len(app.remediationStore.Queue()),
+	)
+
+	for i := 0; i < app.queue.workers; i++ {
+		go app.remediationWorker(ctx)
+	}
+	// Pick up anything queued before the restart
+	app.queue.notify()
+	<-ctx.Done()
+}
+
+// remediationWorker runs queued remediations one at a time
+func (app *App) remediationWorker(ctx context.Context) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-app.queue.wake:
+		case <-ticker.C:
+		}
+
+		for ctx.Err() == nil {
+			record, ok := app.remediationStore.ClaimNext()
+			if !ok {
+				break
+			}
+			// More work may be waiting for another idle worker
+			app.queue.notify()
+			app.runRemediation(record)
+		}
+	}
+}
+
+// runRemediation runs a claimed remediation and records the outcome on the service
+func (app *App) runRemediation(record *RemediationRecord) {
+	app.broadcastRemediation(record.ID)
+	app.broadcastQueue()
+
+	app.recordRemediationOutcome(record, app.remediation.RunAgent(record))
+}
+
+// recordRemediationOutcome logs a finished remediation on its service and
+// broadcasts the result
+func (app *App) recordRemediationOutcome(record *RemediationRecord, err error) {
+	if errors.Is(err, ErrRemediationCancelled) {
+		slog.Info("Remediation cancelled",
+			"service", record.ServiceName,
+			"id", record.ID,
+		)
+		app.store.AddRemediationLog(record.ServiceName,
+			time.Now().Format(time.RFC3339)+" - Remediation cancelled")
+	} else if err != nil {
+		slog.Error("Remediation failed",
+			"service", record.ServiceName,
+			"error", err,
+		)
+		app.store.AddRemediationLog(record.ServiceName,
+			time.Now().Format(time.RFC3339)+" - Remediation failed: "+err.Error())
+	} else {
+		slog.Info("Remediation completed",
+			"service", record.ServiceName,
+		)
+		app.store.AddRemediationLog(record.ServiceName,
+			time.Now().Format(time.RFC3339)+" - Remediation completed successfully")
+	}
+
+	app.broadcastRemediation(record.ID)
+	app.alertRemediationOutcome(record.ID, err)
+
+	// Broadcast final update after remediation completes/fails
+	if updated, ok := app.store.GetService(record.ServiceName); ok {
+		app.BroadcastServiceUpdate(updated)
+	}
+}
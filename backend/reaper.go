@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// JobRetention controls how long finished agent jobs (stopped containers) are
+// kept for inspection
+type JobRetention struct {
+	Keep int           // newest finished jobs kept
+	TTL  time.Duration // finished jobs older than this are removed; 0 keeps them
+}
+
+// expired returns the finished jobs the retention policy removes, given the
+// jobs found on the executor
+func (r JobRetention) expired(jobs []ExecutorJobInfo, now time.Time) []ExecutorJobInfo {
+	var finished []ExecutorJobInfo
+	for _, job := range jobs {
+		if !job.Running {
+			finished = append(finished, job)
+		}
+	}
+	sort.SliceStable(finished, func(i, j int) bool {
+		return finished[i].FinishedAt.After(finished[j].FinishedAt)
+	})
+
+	var expired []ExecutorJobInfo
+	for i, job := range finished {
+		if i >= r.Keep || (r.TTL > 0 && !job.FinishedAt.IsZero() && now.Sub(job.FinishedAt) > r.TTL) {
+			expired = append(expired, job)
+		}
+	}
+	return expired
+}
+
+// reconcileRemediations settles the given remediations, which were running
+// when the backend stopped. Jobs that are still running are re-attached; the
+// rest are marked failed. Running jobs without a running record are orphans
+// and are removed. Returns false, leaving the records alone, when the
+// executor's jobs cannot be listed yet; runJobReaper then tries again.
+func (app *App) reconcileRemediations(ctx context.Context, ids []string) bool {
+	running := make(map[string]RemediationRecord)
+	for _, id := range ids {
+		if record, exists := app.remediationStore.Get(id); exists && record.Status == RemediationRunning {
+			running[id] = *record
+		}
+	}
+
+	if reaper, ok := app.remediation.executor.(ReapingExecutor); ok {
+		if err := reaper.Available(); err != nil {
+			slog.Warn("[REMEDIATION] Executor unavailable - reconciliation deferred", "error", err)
+			return false
+		}
+		listCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		jobs, err := reaper.ListJobs(listCtx)
+		cancel()
+		if err != nil {
+			slog.Warn("[REMEDIATION] Failed to list agent jobs - reconciliation deferred", "error", err)
+			return false
+		}
+
+		for _, job := range jobs {
+			record, wasRunning := running[job.RemediationID]
+			switch {
+			case wasRunning && job.Running:
+				delete(running, job.RemediationID)
+				go app.resumeRemediation(&record, job)
+
+			case job.Running && app.remediationRunning(job.RemediationID):
+				// Started by a worker since the restart
+
+			case job.Running:
+				slog.Warn("[REMEDIATION] Removing orphaned agent job",
+					"id", job.RemediationID,
+					"name", job.Name,
+				)
+				reaper.Remove(ctx, job)
+
+			case wasRunning:
+				// Finished while the backend was down; its report, if any, was lost
+				delete(running, job.RemediationID)
+				app.interruptRemediation(record, "Agent finished while the backend was down")
+				reaper.Remove(ctx, job)
+			}
+		}
+	}
+
+	for _, record := range running {
+		app.interruptRemediation(record, "Interrupted by backend restart")
+	}
+	return true
+}
+
+// remediationRunning reports whether a remediation is marked running
+func (app *App) remediationRunning(id string) bool {
+	record, exists := app.remediationStore.Get(id)
+	return exists && record.Status == RemediationRunning
+}
+
+// runningIDs returns the remediations currently marked running
+func (app *App) runningIDs() []string {
+	var ids []string
+	for _, record := range app.remediationStore.Running() {
+		ids = append(ids, record.ID)
+	}
+	return ids
+}
+
+// resumeRemediation follows a re-attached job to completion
+func (app *App) resumeRemediation(record *RemediationRecord, job ExecutorJobInfo) {
+	app.store.AddRemediationLog(record.ServiceName,
+		time.Now().Format(time.RFC3339)+" - Remediation re-attached after restart")
+	app.recordRemediationOutcome(record, app.remediation.Resume(record, job))
+}
+
+// interruptRemediation fails a remediation whose job is gone
+func (app *App) interruptRemediation(record RemediationRecord, reason string) {
+	slog.Warn("[REMEDIATION] Remediation interrupted",
+		"id", record.ID,
+		"service", record.ServiceName,
+		"reason", reason,
+	)
+	app.remediationStore.SetInterrupted(record.ID, reason)
+	app.store.AddRemediationLog(record.ServiceName,
+		time.Now().Format(time.RFC3339)+" - Remediation failed: "+reason)
+	app.alertRemediationOutcome(record.ID, errors.New(reason))
+}
+
+// runJobReaper periodically removes finished agent jobs outside the retention
+// policy. Remediations that could not be reconciled at startup are retried first.
+func (app *App) runJobReaper(ctx context.Context, unreconciled []string) {
+	reaper, ok := app.remediation.executor.(ReapingExecutor)
+	if !ok {
+		return
+	}
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if len(unreconciled) > 0 {
+				if !app.reconcileRemediations(ctx, unreconciled) {
+					continue
+				}
+				unreconciled = nil
+			}
+			if reaper.Available() != nil {
+				continue
+			}
+			jobs, err := reaper.ListJobs(ctx)
+			if err != nil {
+				slog.Warn("[REMEDIATION] Failed to list agent jobs", "error", err)
+				continue
+			}
+			for _, job := range app.jobRetention.expired(jobs, time.Now()) {
+				reaper.Remove(ctx, job)
+			}
+		}
+	}
+}
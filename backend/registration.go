@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// ErrServiceNotRegistered is returned for heartbeats from unknown services when
+// unregistered heartbeats are not allowed
+var ErrServiceNotRegistered = errors.New("service is not registered")
+
+// ServiceRegistration is the declared metadata for a pre-registered service
+type ServiceRegistration struct {
+	GitHubRepo        string             `json:"github_repo"`
+	RepoPath          string             `json:"repo_path,omitempty"`   // service's directory in a monorepo
+	BaseBranch        string             `json:"base_branch,omitempty"` // branch fixes start from; empty uses the default
+	Owner             string             `json:"owner,omitempty"`       // owning team
+	Tags              []string           `json:"tags,omitempty"`
+	Environment       string             `json:"environment,omitempty"`
+	Description       string             `json:"description,omitempty"`
+	RemediationPolicy *RemediationPolicy `json:"remediation_policy,omitempty"`
+	Check             *CheckConfig       `json:"check,omitempty"`
+	Probe             *ProbeConfig       `json:"probe,omitempty"`
+}
+
+// Validate checks the registration fields
+func (r *ServiceRegistration) Validate() error {
+	if r.GitHubRepo != "" {
+		if err := validateRepoURL(r.GitHubRepo); err != nil {
+			return err
+		}
+	}
+	if r.RepoPath != "" {
+		if err := validateRepoPath(r.RepoPath); err != nil {
+			return err
+		}
+	}
+	if r.BaseBranch != "" {
+		if err := validateBranchName(r.BaseBranch); err != nil {
+			return err
+		}
+	}
+	if r.RemediationPolicy != nil {
+		if err := r.RemediationPolicy.Validate(); err != nil {
+			return err
+		}
+	}
+	if r.Check != nil {
+		if err := r.Check.Validate(); err != nil {
+			return err
+		}
+	}
+	if r.Probe != nil {
+		if err := r.Probe.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply copies the declared metadata onto a service
+func (r *ServiceRegistration) apply(service *Service) {
+	service.GitHubRepo = r.GitHubRepo
+	service.RepoPath = r.RepoPath
+	service.BaseBranch = r.BaseBranch
+	service.Owner = r.Owner
+	service.Tags = r.Tags
+	service.Environment = r.Environment
+	service.Description = r.Description
+	service.RemediationPolicy = r.RemediationPolicy
+	service.Check = r.Check
+	service.Probe = r.Probe
+	service.Registered = true
+}
+
+// RegisterService pre-registers a service before its first heartbeat.
+// Returns false if the service is already registered.
+func (s *ServiceStore) RegisterService(name string, reg ServiceRegistration) (*Service, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	service, exists := s.services[name]
+	if exists && service.Registered {
+		return nil, false
+	}
+
+	now := time.Now()
+	if !exists {
+		service = &Service{
+			Name:   name,
+			Status: StatusPending,
+			Logs:   make([]LogEntry, 0),
+		}
+		s.services[name] = service
+	}
+
+	reg.apply(service)
+	service.RegisteredAt = &now
+	service.addLog(LogEntry{
+		Timestamp: now,
+		Type:      "status",
+		Message:   "Service registered",
+	})
+	s.persist(service)
+
+	slog.Info("Service registered", "service", name, "github_repo", reg.GitHubRepo)
+
+	copy := *service
+	return &copy, true
+}
+
+// UpdateRegistration replaces the declared metadata of a service, registering it
+// if needed. Returns true if the service was newly created.
+func (s *ServiceStore) UpdateRegistration(name string, reg ServiceRegistration) (*Service, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	service, exists := s.services[name]
+	if !exists {
+		service = &Service{
+			Name:   name,
+			Status: StatusPending,
+			Logs:   make([]LogEntry, 0),
+		}
+		s.services[name] = service
+	}
+	if service.RegisteredAt == nil {
+		service.RegisteredAt = &now
+	}
+
+	reg.apply(service)
+	service.addLog(LogEntry{
+		Timestamp: now,
+		Type:      "status",
+		Message:   "Service registration updated",
+	})
+	s.persist(service)
+
+	slog.Info("Service registration updated", "service", name, "github_repo", reg.GitHubRepo)
+
+	copy := *service
+	return &copy, !exists
+}
+
+// DeleteService removes a service and its uptime history.
+// Returns false if the service does not exist.
+func (s *ServiceStore) DeleteService(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.services[name]; !exists {
+		return false
+	}
+
+	for _, bucket := range s.history[name] {
+		if err := s.storage.Delete(bucketUptime, uptimeKey(name, bucket.Start)); err != nil {
+			slog.Error("Failed to delete uptime bucket", "service", name, "error", err)
+		}
+	}
+	delete(s.history, name)
+	delete(s.services, name)
+
+	if err := s.storage.Delete(bucketServices, name); err != nil {
+		slog.Error("Failed to delete service from storage", "service", name, "error", err)
+	}
+
+	slog.Info("Service deleted", "service", name)
+	return true
+}
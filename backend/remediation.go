@@ -1,302 +1,368 @@
 package main
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"os"
-	"strings"
+	"strconv"
+	"sync"
 	"time"
 
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/image"
-	"github.com/docker/docker/client"
-	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/google/uuid"
 )
 
-// RemediationService handles spawning OpenCode containers for auto-fix
+// RemediationService runs a coding agent through a RemediationExecutor
 type RemediationService struct {
-	dockerClient  *client.Client
-	store         *RemediationStore
-	githubPAT     string
-	openCodeImage string
-	cerebrasKey   string
-	backendURL    string
+	executor   RemediationExecutor
+	agents     *AgentRegistry
+	store      *RemediationStore
+	logs       *RemediationLogs
+	gitHosts   *GitHosts
+	backendURL string
+
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc // running remediations
 }
 
-// NewRemediationService creates a new remediation service
-func NewRemediationService(store *RemediationStore) *RemediationService {
-	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		slog.Warn("Failed to create Docker client - remediation disabled", "error", err)
-		return &RemediationService{store: store}
-	}
+// remediationTimeout bounds how long an agent job may run
+const remediationTimeout = 10 * time.Minute
 
-	openCodeImage := os.Getenv("OPENCODE_IMAGE")
-	if openCodeImage == "" {
-		openCodeImage = "ghcr.io/anomalyco/opencode:latest"
-	}
+// ErrRemediationCancelled is returned when a running remediation is cancelled
+var ErrRemediationCancelled = errors.New("remediation cancelled")
 
+// NewRemediationService creates a new remediation service
+func NewRemediationService(store *RemediationStore, logs *RemediationLogs, executor RemediationExecutor, agents *AgentRegistry, gitHosts *GitHosts) *RemediationService {
 	backendURL := os.Getenv("BACKEND_URL")
 	if backendURL == "" {
-		backendURL = "http://host.docker.internal:8080"
+		backendURL = executor.DefaultBackendURL()
+	}
+
+	executorErr := executor.Available()
+	if executorErr != nil {
+		slog.Warn("Remediation executor unavailable - remediation disabled",
+			"executor", executor.Kind(),
+			"error", executorErr,
+		)
 	}
 
 	slog.Info("Remediation service initialized",
-		"docker_client", "connected",
-		"opencode_image", openCodeImage,
+		"executor", executor.Kind(),
+		"executor_available", executorErr == nil,
 		"backend_url", backendURL,
-		"github_pat_set", os.Getenv("GITHUB_PAT") != "",
-		"cerebras_key_set", os.Getenv("CEREBRAS_API_KEY") != "",
+		"git_hosts", gitHosts.Names(),
 	)
 
-	githubPat := os.Getenv("GITHUB_PAT")
-	key := os.Getenv("CEREBRAS_API_KEY")
-
 	return &RemediationService{
-		dockerClient:  dockerClient,
-		store:         store,
-		githubPAT:     githubPat,
-		openCodeImage: openCodeImage,
-		cerebrasKey:   key,
-		backendURL:    backendURL,
+		executor:   executor,
+		agents:     agents,
+		store:      store,
+		logs:       logs,
+		gitHosts:   gitHosts,
+		backendURL: backendURL,
+		cancels:    make(map[string]context.CancelFunc),
 	}
 }
 
-// RunOpenCode spawns an OpenCode container to analyze and fix issues
-func (r *RemediationService) RunOpenCode(repoURL, errorLog, serviceName string) error {
-	// Generate unique ID for this remediation
-	remediationID := uuid.New().String()[:8]
+// newRemediationID generates a unique ID for a remediation
+func newRemediationID() string {
+	return uuid.New().String()[:8]
+}
+
+// RemediationRequest describes a remediation to run
+type RemediationRequest struct {
+	ID          string
+	ServiceName string
+	GitHubRepo  string
+	RepoPath    string // service's directory in a monorepo; the agent may only change files under it
+	BaseBranch  string // branch the fix starts from; empty uses the repo's default
+	ErrorLog    string
+	Signature   string              // error signature used for dedupe
+	Mode        RemediationMode     // auto pushes the fix, suggest only reports it
+	Agent       *AgentSelection     // nil uses the default provider
+	Priority    int                 // queue priority; higher runs first
+	Prompt      string              // extra instructions for the agent
+	TestPolicy  *TestPolicy         // tests run on the fix before it is pushed; nil skips them
+	Context     *RemediationContext // logs, errors and stack frames for the agent; nil sends only the error
+	TriggeredBy string              // policy, manual or retry
+	RetryOf     string              // remediation this one retries
+}
 
-	// Create record in store
-	record := r.store.Create(remediationID, serviceName, repoURL, errorLog)
+// Cancel stops a running remediation. Returns false if it is not running.
+func (r *RemediationService) Cancel(remediationID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cancel, running := r.cancels[remediationID]
+	if running {
+		cancel()
+	}
+	return running
+}
+
+// RunAgent runs the coding agent for a queued remediation that a worker has
+// claimed, to analyze and fix issues
+func (r *RemediationService) RunAgent(record *RemediationRecord) error {
+	remediationID, serviceName, repoURL, errorLog := record.ID, record.ServiceName, record.GitHubRepo, record.ErrorLog
 
 	slog.Info("[REMEDIATION] Starting remediation",
 		"id", remediationID,
 		"service", serviceName,
 		"repo", repoURL,
+		"mode", record.Mode,
+		"executor", r.executor.Kind(),
 	)
 
+	// Create context with timeout; Cancel stops it early
+	ctx, cancel := context.WithTimeout(context.Background(), remediationTimeout)
+	defer cancel()
+	defer r.track(remediationID, cancel)()
+
 	// Validate prerequisites
-	if r.dockerClient == nil {
-		r.store.Complete(remediationID, false, -1, "Docker client not available")
-		return fmt.Errorf("docker client not available")
+	if err := r.executor.Available(); err != nil {
+		r.store.Complete(remediationID, false, -1, "Executor not available: "+err.Error())
+		return err
 	}
 
-	if r.githubPAT == "" {
-		r.store.Complete(remediationID, false, -1, "GITHUB_PAT not set")
-		return fmt.Errorf("GITHUB_PAT environment variable not set")
+	// Heartbeats are validated on the way in; records from before that are not
+	if err := validateServiceName(serviceName); err != nil {
+		r.store.Complete(remediationID, false, -1, err.Error())
+		return err
 	}
-
-	if r.cerebrasKey == "" {
-		r.store.Complete(remediationID, false, -1, "CEREBRAS_API_KEY not set")
-		return fmt.Errorf("CEREBRAS_API_KEY environment variable not set")
+	if err := validateRepoURL(repoURL); err != nil {
+		r.store.Complete(remediationID, false, -1, err.Error())
+		return err
+	}
+	if record.RepoPath != "" {
+		if err := validateRepoPath(record.RepoPath); err != nil {
+			r.store.Complete(remediationID, false, -1, err.Error())
+			return err
+		}
+	}
+	if record.BaseBranch != "" {
+		if err := validateBranchName(record.BaseBranch); err != nil {
+			r.store.Complete(remediationID, false, -1, err.Error())
+			return err
+		}
 	}
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-	defer cancel()
-
-	// Pull the OpenCode image
-	slog.Info("[REMEDIATION] Pulling OpenCode image",
-		"id", remediationID,
-		"image", r.openCodeImage,
-	)
-
-	reader, err := r.dockerClient.ImagePull(ctx, r.openCodeImage, image.PullOptions{})
+	remoteEnv, err := r.gitHosts.RemoteEnv(repoURL)
 	if err != nil {
-		slog.Warn("[REMEDIATION] Failed to pull image, using local",
-			"id", remediationID,
-			"error", err,
-		)
-	} else {
-		io.Copy(io.Discard, reader)
-		reader.Close()
+		r.store.Complete(remediationID, false, -1, err.Error())
+		return err
 	}
 
-	// Build the wrapper script that runs OpenCode and reports back
-	wrapperScript := buildAgentWrapperScript(remediationID, serviceName, repoURL, errorLog, r.backendURL)
-
-	// Container configuration
-	containerName := fmt.Sprintf("highline-fix-%s", remediationID)
-
-	r.store.UpdateStatus(remediationID, RemediationRunning, "", containerName)
-
-	containerConfig := &container.Config{
-		Image: r.openCodeImage,
-		Env: []string{
-			"GITHUB_TOKEN=" + r.githubPAT,
-			"CEREBRAS_API_KEY=" + r.cerebrasKey,
-			"TERM=dumb",
-			"GIT_AUTHOR_NAME=Highline AutoFix",
-			"GIT_AUTHOR_EMAIL=autofix@highline.local",
-			"GIT_COMMITTER_NAME=Highline AutoFix",
-			"GIT_COMMITTER_EMAIL=autofix@highline.local",
-			"REMEDIATION_ID=" + remediationID,
-			"SERVICE_NAME=" + serviceName,
-			"REPO_URL=" + repoURL,
-			"BACKEND_URL=" + r.backendURL,
-		},
-		Entrypoint: []string{"/bin/sh", "-c"},
-		Cmd:        []string{wrapperScript},
-		WorkingDir: "/workspace",
-		Tty:        false,
+	provider, err := r.agents.Resolve(record.Agent)
+	if err != nil {
+		r.store.Complete(remediationID, false, -1, err.Error())
+		return err
 	}
+	r.store.SetAgent(remediationID, provider.Name, provider.Model)
 
-	hostConfig := &container.HostConfig{
-		AutoRemove: false,
-		Resources: container.Resources{
-			Memory:   2 * 1024 * 1024 * 1024,
-			NanoCPUs: 2 * 1e9,
-		},
-		// Allow container to reach host network for callback
-		ExtraHosts: []string{"host.docker.internal:host-gateway"},
+	agentEnv, err := provider.Env()
+	if err != nil {
+		r.store.Complete(remediationID, false, -1, err.Error())
+		return fmt.Errorf("agent %s: %w", provider.Name, err)
 	}
 
-	// Create the container
-	slog.Info("[REMEDIATION] Creating container",
-		"id", remediationID,
-		"container_name", containerName,
-	)
-
-	resp, err := r.dockerClient.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, containerName)
+	// Per-remediation secret the agent signs its report with
+	reportSecret, err := newSecret()
 	if err != nil {
-		r.store.Complete(remediationID, false, -1, fmt.Sprintf("Failed to create container: %v", err))
-		return fmt.Errorf("failed to create container: %w", err)
+		r.store.Complete(remediationID, false, -1, "Failed to generate report secret")
+		return fmt.Errorf("failed to generate report secret: %w", err)
+	}
+	r.store.SetReportSecret(remediationID, reportSecret)
+	reportKeyIpad, reportKeyOpad := hmacKeyPads(reportSecret)
+
+	env := []string{
+		"AGENT_PROMPT=" + buildAgentPrompt(errorLog, record.Prompt, record.RepoPath, record.Context != nil),
+		"GIT_AUTHOR_NAME=Highline AutoFix",
+		"GIT_AUTHOR_EMAIL=autofix@highline.local",
+		"GIT_COMMITTER_NAME=Highline AutoFix",
+		"GIT_COMMITTER_EMAIL=autofix@highline.local",
+		"REMEDIATION_ID=" + remediationID,
+		"SERVICE_NAME=" + serviceName,
+		"REPO_URL=" + repoURL,
+		"REPO_PATH=" + record.RepoPath,
+		"BASE_BRANCH=" + record.BaseBranch,
+		"BACKEND_URL=" + r.backendURL,
+		"REPORT_KEY_IPAD=" + reportKeyIpad,
+		"REPORT_KEY_OPAD=" + reportKeyOpad,
+		"AUTO_PUSH=" + strconv.FormatBool(record.Mode != RemediationModeSuggest),
+		"MAX_DIFF_BYTES=" + strconv.Itoa(maxDiffBytes),
+	}
+	env = append(env, remoteEnv...)
+	env = append(env, testEnv(record.TestPolicy)...)
+	env = append(env, contextEnv(record)...)
+	env = append(env, agentEnv...)
+
+	job := ExecutorJob{
+		RemediationID: remediationID,
+		Name:          jobNamePrefix + remediationID,
+		// Build the wrapper script that runs the agent and reports back
+		Script: buildAgentWrapperScript(),
+		Env:    env,
+		Output: func(line string) {
+			r.logs.Append(remediationID, line)
+		},
 	}
 
-	r.store.UpdateStatus(remediationID, RemediationRunning, resp.ID, containerName)
-	record.ContainerID = resp.ID
+	r.store.UpdateStatus(remediationID, RemediationRunning, "", job.Name)
 
-	slog.Info("[REMEDIATION] Container created",
-		"id", remediationID,
-		"container_id", resp.ID[:12],
-	)
+	result, err := r.executor.Run(ctx, job, func(handle string) {
+		r.store.UpdateStatus(remediationID, RemediationRunning, handle, job.Name)
+	})
+	return r.recordResult(ctx, remediationID, result, err)
+}
 
-	// Ensure cleanup
-	// defer r.cleanupContainer(context.Background(), resp.ID, containerName, remediationID)
+// Resume follows a job that was running when the backend restarted and
+// records its outcome. The original timeout still applies.
+func (r *RemediationService) Resume(record *RemediationRecord, job ExecutorJobInfo) error {
+	remediationID := record.ID
+	reaper, ok := r.executor.(ReapingExecutor)
+	if !ok {
+		r.store.SetInterrupted(remediationID, "Interrupted by backend restart")
+		return fmt.Errorf("%s executor cannot resume jobs", r.executor.Kind())
+	}
 
-	// Start the container
-	if err := r.dockerClient.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
-		r.store.Complete(remediationID, false, -1, fmt.Sprintf("Failed to start container: %v", err))
-		return fmt.Errorf("failed to start container: %w", err)
+	started := record.StartTime
+	if record.StartedAt != nil {
+		started = *record.StartedAt
 	}
+	ctx, cancel := context.WithDeadline(context.Background(), started.Add(remediationTimeout))
+	defer cancel()
+	defer r.track(remediationID, cancel)()
 
-	slog.Info("[REMEDIATION] Container started - OpenCode agent is working",
+	slog.Info("[REMEDIATION] Re-attaching to agent after restart",
 		"id", remediationID,
-		"container_id", resp.ID[:12],
+		"handle", job.Handle,
 	)
 
-	// Stream logs in background
-	go r.streamContainerLogs(ctx, resp.ID, remediationID)
-
-	// Wait for completion
-	statusCh, errCh := r.dockerClient.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
-
-	select {
-	case err := <-errCh:
-		if err != nil {
-			r.store.Complete(remediationID, false, -1, fmt.Sprintf("Error waiting: %v", err))
-			return fmt.Errorf("error waiting for container: %w", err)
-		}
+	result, err := reaper.Attach(ctx, ExecutorJob{
+		RemediationID: remediationID,
+		Name:          job.Name,
+		Output: func(line string) {
+			r.logs.Append(remediationID, line)
+		},
+	}, job.Handle)
+	return r.recordResult(ctx, remediationID, result, err)
+}
 
-	case status := <-statusCh:
-		success := status.StatusCode == 0
-		r.store.Complete(remediationID, success, status.StatusCode, "")
+// track registers a running remediation for Cancel and returns the func that
+// unregisters it
+func (r *RemediationService) track(remediationID string, cancel context.CancelFunc) func() {
+	r.mu.Lock()
+	r.cancels[remediationID] = cancel
+	r.mu.Unlock()
+
+	return func() {
+		r.mu.Lock()
+		delete(r.cancels, remediationID)
+		r.mu.Unlock()
+	}
+}
 
-		if success {
-			slog.Info("[REMEDIATION] Container completed successfully",
+// recordResult stores the outcome of an agent job
+func (r *RemediationService) recordResult(ctx context.Context, remediationID string, result ExecutorResult, err error) error {
+	if err != nil {
+		// Classify by the context: executors may wrap or replace its error
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			r.store.SetTimedOut(remediationID)
+			slog.Error("[REMEDIATION] Timeout - agent stopped",
 				"id", remediationID,
-				"exit_code", 0,
 			)
-		} else {
-			slog.Error("[REMEDIATION] Container failed",
+			return fmt.Errorf("remediation timed out")
+		}
+		if errors.Is(ctx.Err(), context.Canceled) {
+			r.store.SetCancelled(remediationID)
+			slog.Info("[REMEDIATION] Cancelled - agent stopped",
 				"id", remediationID,
-				"exit_code", status.StatusCode,
 			)
-			return fmt.Errorf("container exited with code %d", status.StatusCode)
+			return ErrRemediationCancelled
+		}
+		r.store.Complete(remediationID, false, -1, err.Error())
+		return err
+	}
+
+	// The verified report decides the outcome: a rejected or blocked fix is a
+	// failure even if the agent exited cleanly. Without a report only the
+	// exit code is known.
+	success := result.ExitCode == 0
+	var reportErr string
+	if record, ok := r.store.Get(remediationID); ok && record.AgentReport != nil {
+		success = record.AgentReport.Success
+		if !success {
+			reportErr = record.AgentReport.Summary
 		}
+	}
+	r.store.Complete(remediationID, success, result.ExitCode, reportErr)
 
-	case <-ctx.Done():
-		r.store.SetTimedOut(remediationID)
-		slog.Error("[REMEDIATION] Timeout - stopping container",
+	if !success {
+		slog.Error("[REMEDIATION] Agent failed",
 			"id", remediationID,
+			"exit_code", result.ExitCode,
+			"summary", reportErr,
 		)
-		stopTimeout := 10
-		r.dockerClient.ContainerStop(context.Background(), resp.ID, container.StopOptions{Timeout: &stopTimeout})
-		return fmt.Errorf("remediation timed out")
+		if reportErr != "" {
+			return errors.New(reportErr)
+		}
+		return fmt.Errorf("agent exited with code %d", result.ExitCode)
 	}
 
-	return nil
-}
-
-// cleanupContainer removes the container
-func (r *RemediationService) cleanupContainer(ctx context.Context, containerID, containerName, remediationID string) {
-	slog.Info("[REMEDIATION] Cleaning up container",
+	slog.Info("[REMEDIATION] Agent completed successfully",
 		"id", remediationID,
-		"container_id", containerID[:12],
+		"exit_code", result.ExitCode,
 	)
+	return nil
+}
 
-	err := r.dockerClient.ContainerRemove(ctx, containerID, container.RemoveOptions{
-		Force:         true,
-		RemoveVolumes: true,
-	})
-
-	if err != nil {
-		slog.Warn("[REMEDIATION] Failed to remove container",
-			"id", remediationID,
-			"error", err,
-		)
-	} else {
-		slog.Info("[REMEDIATION] Container removed",
-			"id", remediationID,
-		)
+// testEnv passes the test policy to the wrapper script. Without a test
+// command the script skips the test run.
+func testEnv(policy *TestPolicy) []string {
+	if policy == nil {
+		return nil
+	}
+	timeout := time.Duration(policy.Timeout)
+	if timeout == 0 {
+		timeout = defaultTestTimeout
+	}
+	onFailure := policy.OnFailure
+	if onFailure == "" {
+		onFailure = TestFailureBlock
+	}
+	return []string{
+		"TEST_COMMAND=" + policy.Command,
+		"TEST_ON_FAILURE=" + string(onFailure),
+		"TEST_TIMEOUT=" + strconv.Itoa(int(timeout.Seconds())),
+		"MAX_TEST_OUTPUT_BYTES=" + strconv.Itoa(maxTestOutputBytes),
 	}
 }
 
-// streamContainerLogs streams container logs properly handling the Docker multiplexed stream
-func (r *RemediationService) streamContainerLogs(ctx context.Context, containerID, remediationID string) {
-	reader, err := r.dockerClient.ContainerLogs(ctx, containerID, container.LogsOptions{
-		ShowStdout: true,
-		ShowStderr: true,
-		Follow:     true,
-		Timestamps: false,
-	})
-	if err != nil {
-		return
+// contextEnv passes the context bundle to the wrapper script, which writes it
+// to contextFileName in the clone
+func contextEnv(record *RemediationRecord) []string {
+	if record.Context == nil {
+		return nil
 	}
-	defer reader.Close()
-
-	// Use a pipe to convert the multiplexed stream into a readable format
-	stdoutReader, stdoutWriter := io.Pipe()
-
-	go func() {
-		// StdCopy handles the 8-byte Docker headers correctly
-		// It will split the multiplexed stream from reader into stdoutWriter and stderrWriter (we use same for both)
-		_, err := stdcopy.StdCopy(stdoutWriter, stdoutWriter, reader)
-		if err != nil {
-			slog.Error("[REMEDIATION][LOG_ERROR]", "id", remediationID, "error", err)
-		}
-		stdoutWriter.Close()
-	}()
-
-	scanner := bufio.NewScanner(stdoutReader)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.TrimSpace(line) != "" {
-			slog.Info("[REMEDIATION][LOG]",
-				"id", remediationID,
-				"content", line,
-			)
-		}
+	env := []string{
+		"CONTEXT_FILE=" + contextFileName,
+		"REMEDIATION_CONTEXT=" + record.Context.Render(record.ServiceName, record.ErrorLog),
+	}
+	// Only a plain object name is handed to git
+	if commitHashPattern.MatchString(record.Context.Commit) {
+		env = append(env, "DEPLOYED_COMMIT="+record.Context.Commit)
 	}
+	return env
 }
 
-// buildAgentWrapperScript creates a shell script that runs OpenCode and reports back
-func buildAgentWrapperScript(remediationID, serviceName, repoURL, errorLog, backendURL string) string {
+// buildAgentPrompt creates the task given to the agent, with optional extra
+// instructions from whoever requested the remediation. A repoPath limits the
+// agent to the service's directory; withContext points it at the context bundle.
+func buildAgentPrompt(errorLog, instructions, repoPath string, withContext bool) string {
+	errorLog = capText(errorLog, maxPromptErrorBytes)
+	instructions = capText(instructions, maxPromptInstructionBytes)
+
 	// Simple test prompt - focus only on code changes
 	prompt := fmt.Sprintf(`
 You are in a git repository. Your ONLY task is to analyze the following error and fix the code to resolve it:
@@ -308,121 +374,324 @@ Guidelines:
 2. DO NOT commit your changes.
 3. DO NOT push your changes.
 4. DO NOT create new branches.
-Just make the necessary code edits to fix the bug.`, strings.ReplaceAll(errorLog, "'", "'\"'\"'"))
+Just make the necessary code edits to fix the bug.`, errorLog)
+
+	if repoPath != "" {
+		prompt += "\n\nThe service lives in " + repoPath + "/ of a larger repository and that is your working directory. " +
+			"Only change files under it: a fix that touches anything outside " + repoPath + "/ is rejected."
+	}
+	if withContext {
+		prompt += "\n\nRecent logs, related errors, the stack frames they mention and the deployed commit are in " +
+			contextFileName + " in your working directory. Read it before changing code; do not edit it."
+	}
+	if instructions != "" {
+		prompt += "\n\nAdditional instructions:\n" + instructions
+	}
+	return prompt
+}
 
+// buildAgentWrapperScript creates a shell script that runs the agent and reports back.
+// The script is the same for every job: the remediation, service, repo, agent
+// command, config and prompt all come from env vars, so no request data is
+// ever parsed as shell.
+func buildAgentWrapperScript() string {
 	// Shell script that handles git mechanistically
 	return fmt.Sprintf(`#!/bin/sh
+# The report secret arrives as HMAC pads that are written to files before
+# tracing starts, so it never shows up in the output or on a command line
+REPORT_KEY_DIR=$(mktemp -d)
+printf "$REPORT_KEY_IPAD" > "$REPORT_KEY_DIR/ipad"
+printf "$REPORT_KEY_OPAD" > "$REPORT_KEY_DIR/opad"
+unset REPORT_KEY_IPAD REPORT_KEY_OPAD
 set -x
 
+# json_str TEXT: escape TEXT for use inside a JSON string
+json_str() {
+    printf '%%s' "$1" | tr -d '\000-\010\013-\037' | sed 's/\\/\\\\/g; s/"/\\"/g; s/	/\\t/g' |
+        awk 'NR > 1 { printf "%%s", "\\n" } { printf "%%s", $0 }'
+}
+
+# post_signed PATH BODY: POST a JSON body to the backend, signed with the
+# per-remediation secret as HMAC-SHA256 over "<timestamp>.<body>". Tracing
+# is off while signing so the key stays out of the output, and the body goes
+# through a file because a report with a large diff does not fit in argv.
+post_signed() {
+    { set +x; } 2>/dev/null
+    POST_TS=$(date +%%s)
+    POST_BODY="$REPORT_KEY_DIR/body"
+    printf '%%s' "$2" > "$POST_BODY"
+    POST_SIG=$({
+        cat "$REPORT_KEY_DIR/opad"
+        { cat "$REPORT_KEY_DIR/ipad"; printf '%%s.' "$POST_TS"; cat "$POST_BODY"; } | openssl dgst -sha256 -binary
+    } | openssl dgst -sha256 | sed 's/^.*= //')
+    wget -qO- --post-file="$POST_BODY" \
+        --header='Content-Type: application/json' \
+        --header="X-Highline-Timestamp: $POST_TS" \
+        --header="X-Highline-Signature: sha256=$POST_SIG" \
+        "$BACKEND_URL$1"
+    POST_EXIT=$?
+    set -x
+    return $POST_EXIT
+}
+
+# report_phase PHASE [DETAIL]: tell the backend how far the agent got
+report_phase() {
+    post_signed /api/remediation/progress '{"version": %d, "remediation_id": "'"$REMEDIATION_ID"'", "phase": "'"$1"'", "detail": "'"$(json_str "$2")"'"}' >/dev/null ||
+        echo "Failed to report phase $1"
+}
+
 echo "=== HIGHLINE AGENT STARTED ==="
-echo "Remediation ID: %s"
-echo "Service: %s"
-echo "Repository: %s"
+echo "Remediation ID: $REMEDIATION_ID"
+echo "Service: $SERVICE_NAME"
+echo "Repository: $REPO_URL"
+if [ -n "$REPO_PATH" ]; then
+    echo "Path: $REPO_PATH"
+fi
 echo ""
 
-# Pre-install essential dev tools
+# Pre-install essential dev tools (the local executor expects them on the host)
 echo "=== INSTALLING DEV TOOLS ==="
-if command -v apk >/dev/null; then
-    apk add --no-cache git 
-elif command -v apt-get >/dev/null; then
-    apt-get update && apt-get install -y git
+if ! command -v git >/dev/null || ! command -v openssl >/dev/null ||
+    { [ -n "$GIT_SSH_KEY" ] && ! command -v ssh >/dev/null; }; then
+    if command -v apk >/dev/null; then
+        apk add --no-cache git openssl openssh-client
+    elif command -v apt-get >/dev/null; then
+        apt-get update && apt-get install -y git openssl openssh-client
+    fi
 fi
 
-# Configure OpenCode for Cerebras
-echo "=== CONFIGURING OPENCODE ==="
-mkdir -p $HOME/.config/opencode
-cat > $HOME/.config/opencode/opencode.json << 'CONFIGEOF'
-{
-  "$schema": "https://opencode.ai/config.json",
-  "model": "cerebras/gpt-oss-120b",
-  "provider": {
-    "cerebras": {
-      "api": "openai",
-      "name": "Cerebras",
-      "models": {
-        "gpt-oss-120b": {
-          "id": "gpt-oss-120b",
-          "name": "OpenAI GPT-OSS 120B",
-          "context_length": 131072
-        }
-      },
-      "options": {
-        "baseURL": "https://api.cerebras.ai/v1",
-        "apiKey": "{env:CEREBRAS_API_KEY}"
-      }
-    }
-  }
-}
-CONFIGEOF
+# Write the agent's config file, if it has one
+echo "=== CONFIGURING AGENT: $AGENT_NAME ($AGENT_MODEL) ==="
+if [ -n "$AGENT_CONFIG_PATH" ]; then
+    mkdir -p "$(dirname "$HOME/$AGENT_CONFIG_PATH")"
+    printf '%%s' "$AGENT_CONFIG" > "$HOME/$AGENT_CONFIG_PATH"
+fi
+# The task is also written to a file, for agents that read it from one
+export AGENT_PROMPT_FILE="$HOME/.highline-prompt.md"
+printf '%%s\n' "$AGENT_PROMPT" > "$AGENT_PROMPT_FILE"
 
-# Setup workspace
-mkdir -p /workspace
-cd /workspace
+# Setup workspace (executors may override the location)
+WORKSPACE=${WORKSPACE:-/workspace}
+mkdir -p "$WORKSPACE"
+cd "$WORKSPACE"
 
 # Mechanistically clone and setup
 echo "=== MECHANISTIC SETUP ==="
-# Strip https:// from repoURL for token auth
-REPO_PATH=$(echo "%s" | sed 's|https://||')
-git clone "https://x-access-token:$GITHUB_TOKEN@$REPO_PATH" repo
-cd /workspace/repo
+# Git credentials for the repo's host: an HTTPS token handed to git by a
+# credential helper, or an SSH deploy key. Tracing is off so they stay out
+# of the output.
+{ set +x; } 2>/dev/null
+if [ -n "$GIT_SSH_KEY" ]; then
+    mkdir -p "$HOME/.ssh"
+    chmod 700 "$HOME/.ssh"
+    printf '%%s\n' "$GIT_SSH_KEY" > "$HOME/.ssh/highline_deploy_key"
+    chmod 600 "$HOME/.ssh/highline_deploy_key"
+    printf '%%s\n' "$GIT_KNOWN_HOSTS" > "$HOME/.ssh/highline_known_hosts"
+    export GIT_SSH_COMMAND="ssh -i '$HOME/.ssh/highline_deploy_key' -o IdentitiesOnly=yes -o StrictHostKeyChecking=yes -o UserKnownHostsFile='$HOME/.ssh/highline_known_hosts'"
+fi
+if [ -n "$GIT_TOKEN" ]; then
+    git config --global credential.helper '!f() { test "$1" = get && echo "username=$GIT_USERNAME" && echo "password=$GIT_TOKEN"; }; f'
+fi
+set -x
+if [ -n "$BASE_BRANCH" ]; then
+    git clone --branch "$BASE_BRANCH" "$CLONE_URL" repo
+else
+    git clone "$CLONE_URL" repo
+fi
+cd "$WORKSPACE/repo"
+# Everything is diffed against the cloned commit, so changes the agent commits
+# itself are still seen
+BASE_SHA=$(git rev-parse HEAD)
+report_phase cloned "$(git rev-parse --short HEAD)"
+
+# A service in a monorepo is scoped to its directory: the agent and the tests
+# run there, and changes outside it are rejected before anything is committed
+SCOPE_DIR="$WORKSPACE/repo"
+if [ -n "$REPO_PATH" ]; then
+    SCOPE_DIR="$WORKSPACE/repo/$REPO_PATH"
+    if [ ! -d "$SCOPE_DIR" ]; then
+        echo "Repo path $REPO_PATH does not exist in $REPO_URL"
+        exit 1
+    fi
+fi
 
 # Create a unique branch for this fix
-BRANCH_NAME="highline-fix-%s"
+BRANCH_NAME="highline-fix-$REMEDIATION_ID"
 git checkout -b "$BRANCH_NAME"
 
 # Configure git identity
 git config user.name "Highline AutoFix"
 git config user.email "autofix@highline.local"
 
-# Run OpenCode ONLY to fix the code
-echo "=== RUNNING OPENCODE (EDIT MODE) ==="
-opencode run '%s' 2>&1 || OPENCODE_EXIT=$?
-OPENCODE_EXIT=${OPENCODE_EXIT:-0}
+# Write the context bundle for the agent, kept out of the commit. Commits made
+# since the deployed one are appended when the clone has it.
+if [ -n "$CONTEXT_FILE" ]; then
+    CONTEXT_FILE="${REPO_PATH:+$REPO_PATH/}$CONTEXT_FILE"
+    printf '%%s\n' "$REMEDIATION_CONTEXT" > "$CONTEXT_FILE"
+    echo "/$CONTEXT_FILE" >> .git/info/exclude
+    if [ -n "$DEPLOYED_COMMIT" ] && git cat-file -e "$DEPLOYED_COMMIT^{commit}" 2>/dev/null; then
+        {
+            echo "## Commits since the deployed commit"
+            echo ""
+            git log --no-decorate --format='- %%h %%s (%%an, %%ad)' --date=short "$DEPLOYED_COMMIT..HEAD" -- "${REPO_PATH:-.}" | head -n 50
+        } >> "$CONTEXT_FILE"
+    fi
+fi
+
+# Run the agent ONLY to fix the code
+echo "=== RUNNING AGENT (EDIT MODE) ==="
+report_phase analyzing "$AGENT_NAME ($AGENT_MODEL)"
+(cd "$SCOPE_DIR" && sh -c "$AGENT_COMMAND") 2>&1 || AGENT_EXIT=$?
+AGENT_EXIT=${AGENT_EXIT:-0}
 
 echo "=== CHECKING FOR CHANGES ==="
 git status
 CHANGES=$(git status --porcelain)
+# The wrapper makes the only commit; one made by the agent is never pushed
+AGENT_COMMITS=$(git rev-list "$BASE_SHA..HEAD")
+if [ -n "$AGENT_COMMITS" ]; then
+    CHANGES="committed by agent"
+fi
 
 COMMIT_HASH=""
 PUSHED="false"
+FILES_JSON=""
+DIFF_B64=""
+DIFF_BYTES=0
+DIFF_TRUNCATED="false"
+TESTS_JSON="null"
+TEST_RESULT=""
+OUT_OF_SCOPE=""
 
 if [ -n "$CHANGES" ]; then
     echo "Changes detected! Mechanistically committing and pushing..."
     git add .
-    git commit -m "fix: automatically applied remediation for %s"
-    COMMIT_HASH=$(git rev-parse HEAD)
-    
-    echo "Pushing to origin..."
-    git push origin "$BRANCH_NAME" && PUSHED="true"
+
+    # Capture the change for the report: file list as JSON strings and the
+    # unified diff, cut at MAX_DIFF_BYTES and base64 encoded. git quotes names
+    # holding control characters, so each name is one line.
+    FILES_JSON=$(git -c core.quotePath=false diff --cached --no-renames --name-only "$BASE_SHA" |
+        while IFS= read -r f; do printf '"%%s"\n' "$(json_str "$f")"; done | paste -sd, -)
+    git diff --cached "$BASE_SHA" > "$WORKSPACE/fix.diff"
+    DIFF_BYTES=$(wc -c < "$WORKSPACE/fix.diff" | tr -d ' ')
+    if [ "$DIFF_BYTES" -gt "$MAX_DIFF_BYTES" ]; then
+        DIFF_TRUNCATED="true"
+    fi
+    DIFF_B64=$(head -c "$MAX_DIFF_BYTES" "$WORKSPACE/fix.diff" | openssl base64 -A)
+    report_phase edited "$(git diff --cached --name-only "$BASE_SHA" | wc -l | tr -d ' ') file(s) changed"
+
+    # Renames are listed as a delete and an add so moving a file in from
+    # elsewhere in the repo counts as touching both paths
+    if [ -n "$REPO_PATH" ]; then
+        OUT_OF_SCOPE=$(git diff --cached --no-renames --name-only "$BASE_SHA" | awk -v prefix="$REPO_PATH/" 'index($0, prefix) != 1')
+    fi
+
+    if [ -n "$AGENT_COMMITS" ]; then
+        echo "The agent made its own commits - not committing or pushing:"
+        git log --oneline "$BASE_SHA..HEAD"
+    elif [ -n "$OUT_OF_SCOPE" ]; then
+        echo "Changes outside $REPO_PATH - not committing:"
+        echo "$OUT_OF_SCOPE"
+    else
+        # Run the service's tests on the fix, if it has a test command. Only the
+        # tail of the output is reported.
+        if [ -n "$TEST_COMMAND" ]; then
+            echo "=== RUNNING TESTS: $TEST_COMMAND ==="
+            TEST_RUNNER=""
+            if command -v timeout >/dev/null; then
+                TEST_RUNNER="timeout $TEST_TIMEOUT"
+            fi
+            ( cd "$SCOPE_DIR" && $TEST_RUNNER sh -c "$TEST_COMMAND" 2>&1; echo $? > "$WORKSPACE/test.exit" ) | tee "$WORKSPACE/test.log"
+            TEST_EXIT=$(cat "$WORKSPACE/test.exit")
+            if [ "$TEST_EXIT" -eq 0 ]; then
+                TEST_RESULT="passed"
+            else
+                TEST_RESULT="failed"
+            fi
+            if [ "$TEST_EXIT" -eq 124 ] && [ -n "$TEST_RUNNER" ]; then
+                echo "Tests timed out after ${TEST_TIMEOUT}s"
+                echo "Tests timed out after ${TEST_TIMEOUT}s" >> "$WORKSPACE/test.log"
+            fi
+            TEST_B64=$(tail -c "$MAX_TEST_OUTPUT_BYTES" "$WORKSPACE/test.log" | openssl base64 -A)
+            TESTS_JSON='{"command": "'"$(json_str "$TEST_COMMAND")"'", "result": "'"$TEST_RESULT"'", "exit_code": '$TEST_EXIT', "output_base64": "'"$TEST_B64"'"}'
+            report_phase tests_run "$TEST_RESULT (exit $TEST_EXIT)"
+        fi
+
+        git commit -m "fix: automatically applied remediation for $SERVICE_NAME"
+        COMMIT_HASH=$(git rev-parse HEAD)
+        report_phase committed "$COMMIT_HASH"
+
+        if [ "$AUTO_PUSH" = "false" ]; then
+            echo "Suggest-only mode - not pushing."
+        elif [ "$TEST_RESULT" = "failed" ] && [ "$TEST_ON_FAILURE" != "push" ]; then
+            echo "Tests failed - not pushing."
+        else
+            echo "Pushing to origin..."
+            git push origin "$BRANCH_NAME" && PUSHED="true"
+            if [ "$PUSHED" = "true" ]; then
+                report_phase pushed "$BRANCH_NAME"
+            fi
+        fi
+    fi
 else
     echo "No changes were made by the agent."
 fi
 
 # Determine success
-if [ -n "$COMMIT_HASH" ] && [ "$PUSHED" = "true" ]; then
+if [ -n "$AGENT_COMMITS" ]; then
+    SUCCESS="false"
+    SUMMARY="Fix rejected: the agent committed its changes itself ($(echo "$AGENT_COMMITS" | wc -l | tr -d ' ') commit(s))"
+elif [ -n "$OUT_OF_SCOPE" ]; then
+    SUCCESS="false"
+    SUMMARY="Fix rejected: changed files outside $REPO_PATH ($(echo "$OUT_OF_SCOPE" | head -n 5 | paste -sd, - | sed 's/,/, /g'))"
+elif [ -n "$COMMIT_HASH" ] && [ "$PUSHED" = "true" ] && [ "$TEST_RESULT" = "failed" ]; then
+    SUCCESS="true"
+    SUMMARY="Pushed fix to branch $BRANCH_NAME with failing tests (exit: $TEST_EXIT)"
+elif [ -n "$COMMIT_HASH" ] && [ "$PUSHED" = "true" ]; then
     SUCCESS="true"
     SUMMARY="Successfully applied and pushed fix to branch $BRANCH_NAME"
+elif [ -n "$COMMIT_HASH" ] && [ "$TEST_RESULT" = "failed" ] && [ "$AUTO_PUSH" != "false" ]; then
+    SUCCESS="false"
+    SUMMARY="Tests failed on branch $BRANCH_NAME (exit: $TEST_EXIT) - fix not pushed"
+elif [ -n "$COMMIT_HASH" ] && [ "$AUTO_PUSH" = "false" ]; then
+    SUCCESS="true"
+    SUMMARY="Fix suggested on branch $BRANCH_NAME (not pushed)"
 else
     SUCCESS="false"
-    SUMMARY="Failed to apply or push fix (exit: $OPENCODE_EXIT)"
+    SUMMARY="Failed to apply or push fix (exit: $AGENT_EXIT)"
 fi
 
 echo ""
 echo "=== SENDING REPORT TO BACKEND ==="
-echo "Reporting to: %s/api/remediation/report"
-wget -qO- --post-data='{
-        "remediation_id": "%s",
+echo "Reporting to: $BACKEND_URL/api/remediation/report"
+REPORT_BODY='{
+        "version": %d,
+        "remediation_id": "'"$REMEDIATION_ID"'",
         "success": '$SUCCESS',
-        "summary": "'"$SUMMARY"'",
+        "summary": "'"$(json_str "$SUMMARY")"'",
         "commit_hash": "'"$COMMIT_HASH"'",
         "pushed": '$PUSHED',
-        "files_changed": ["'$(echo "$CHANGES" | tr '\n' ',' | sed 's/,$//')'"],
-        "logs": "OpenCode exit: '"$OPENCODE_EXIT"'"
-    }' --header='Content-Type: application/json' "%s/api/remediation/report" || echo "Failed to send report (exit: $?)"
+        "files_changed": ['"$FILES_JSON"'],
+        "diff_base64": "'"$DIFF_B64"'",
+        "diff_bytes": '$DIFF_BYTES',
+        "diff_truncated": '$DIFF_TRUNCATED',
+        "tests": '"$TESTS_JSON"',
+        "logs": "'"$(json_str "Agent exit: $AGENT_EXIT")"'"
+    }'
+
+post_signed /api/remediation/report "$REPORT_BODY" || echo "Failed to send report (exit: $?)"
 
 echo ""
 echo "=== AGENT COMPLETE ==="
 
-exit $OPENCODE_EXIT
-`, remediationID, serviceName, repoURL, repoURL, remediationID, strings.ReplaceAll(prompt, "'", "'\"'\"'"), serviceName, backendURL, remediationID, backendURL)
+# A fix that was rejected or not delivered fails the job even if the agent
+# itself exited cleanly. One blocked by its tests exits with their code.
+if [ "$SUCCESS" != "true" ] && [ "$AGENT_EXIT" -eq 0 ]; then
+    if [ "$TEST_RESULT" = "failed" ]; then
+        exit "$TEST_EXIT"
+    fi
+    exit 1
+fi
+exit $AGENT_EXIT
+`, agentProtocolVersion, agentProtocolVersion)
 }
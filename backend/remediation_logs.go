@@ -0,0 +1,119 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	maxRemediationLogLineBytes = 4096 // longer lines are cut
+	maxRemediationLogBuffers   = 100  // matches the records kept by RemediationStore
+)
+
+// RemediationLogLine is one line of agent output
+type RemediationLogLine struct {
+	Offset int       `json:"offset"` // position in the remediation's output, from 0
+	Time   time.Time `json:"time"`
+	Text   string    `json:"text"`
+}
+
+// RemediationLogBatch is a run of lines from one remediation's output. It is
+// both the /logs response and the remediation_log WebSocket payload.
+type RemediationLogBatch struct {
+	ID        string               `json:"id"`
+	Lines     []RemediationLogLine `json:"lines"`
+	Next      int                  `json:"next"`                // offset to resume from
+	Truncated bool                 `json:"truncated,omitempty"` // lines before the requested offset were dropped
+}
+
+// remediationLogBuffer holds the most recent lines of one remediation
+type remediationLogBuffer struct {
+	lines []RemediationLogLine
+	next  int
+}
+
+// RemediationLogs keeps a bounded buffer of agent output per remediation, in
+// memory only
+type RemediationLogs struct {
+	mu       sync.RWMutex
+	maxLines int
+	buffers  map[string]*remediationLogBuffer
+	order    []string // remediation IDs, oldest first
+	onAppend func(id string, line RemediationLogLine)
+}
+
+// NewRemediationLogs creates a log store keeping up to maxLines per remediation
+func NewRemediationLogs(maxLines int) *RemediationLogs {
+	return &RemediationLogs{
+		maxLines: maxLines,
+		buffers:  make(map[string]*remediationLogBuffer),
+	}
+}
+
+// OnAppend registers a callback for every line added
+func (l *RemediationLogs) OnAppend(fn func(id string, line RemediationLogLine)) {
+	l.onAppend = fn
+}
+
+// Append adds a line to a remediation's buffer, dropping its oldest line when full
+func (l *RemediationLogs) Append(id, text string) {
+	if len(text) > maxRemediationLogLineBytes {
+		text = truncateUTF8(text, maxRemediationLogLineBytes) + "…"
+	}
+
+	l.mu.Lock()
+	buf, exists := l.buffers[id]
+	if !exists {
+		buf = &remediationLogBuffer{}
+		l.buffers[id] = buf
+		l.order = append(l.order, id)
+		if len(l.order) > maxRemediationLogBuffers {
+			delete(l.buffers, l.order[0])
+			l.order = l.order[1:]
+		}
+	}
+
+	line := RemediationLogLine{Offset: buf.next, Time: time.Now(), Text: text}
+	buf.next++
+	buf.lines = append(buf.lines, line)
+	if len(buf.lines) > l.maxLines {
+		buf.lines = buf.lines[len(buf.lines)-l.maxLines:]
+	}
+	l.mu.Unlock()
+
+	if l.onAppend != nil {
+		l.onAppend(id, line)
+	}
+}
+
+// Since returns the buffered lines of a remediation from offset on
+func (l *RemediationLogs) Since(id string, offset int) RemediationLogBatch {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	batch := RemediationLogBatch{ID: id, Lines: []RemediationLogLine{}}
+	buf, exists := l.buffers[id]
+	if !exists {
+		return batch
+	}
+
+	batch.Next = buf.next
+	for _, line := range buf.lines {
+		if line.Offset >= offset {
+			batch.Lines = append(batch.Lines, line)
+		}
+	}
+	if len(buf.lines) > 0 && offset < buf.lines[0].Offset {
+		batch.Truncated = true
+	}
+	return batch
+}
+
+// broadcastRemediationLog pushes a new line to the clients following its remediation
+func (app *App) broadcastRemediationLog(id string, line RemediationLogLine) {
+	app.wsHub.SendToSubscribers(id, "remediation_log", RemediationLogBatch{
+		ID:    id,
+		Lines: []RemediationLogLine{line},
+		Next:  line.Offset + 1,
+	})
+}
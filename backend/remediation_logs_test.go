@@ -0,0 +1,18 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRemediationLogsCutLongLines(t *testing.T) {
+	logs := NewRemediationLogs(10)
+	// The cut falls in the middle of a two-byte rune
+	logs.Append("abc", "x"+strings.Repeat("é", maxRemediationLogLineBytes))
+
+	text := logs.Since("abc", 0).Lines[0].Text
+	if !utf8.ValidString(text) || !strings.HasSuffix(text, "é…") || len(text) > maxRemediationLogLineBytes+len("…") {
+		t.Errorf("cut line is %d bytes, valid UTF-8 %v, ends %q", len(text), utf8.ValidString(text), text[len(text)-8:])
+	}
+}
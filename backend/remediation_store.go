@@ -1,6 +1,10 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
 	"sync"
 	"time"
 )
@@ -9,11 +13,12 @@ import (
 type RemediationStatus string
 
 const (
-	RemediationPending    RemediationStatus = "pending"
-	RemediationRunning    RemediationStatus = "running"
-	RemediationSuccess    RemediationStatus = "success"
-	RemediationFailed     RemediationStatus = "failed"
-	RemediationTimedOut   RemediationStatus = "timed_out"
+	RemediationPending   RemediationStatus = "pending" // queued, waiting for a worker
+	RemediationRunning   RemediationStatus = "running"
+	RemediationSuccess   RemediationStatus = "success"
+	RemediationFailed    RemediationStatus = "failed"
+	RemediationTimedOut  RemediationStatus = "timed_out"
+	RemediationCancelled RemediationStatus = "cancelled"
 )
 
 // RemediationRecord stores the full history of a remediation attempt
@@ -21,6 +26,8 @@ type RemediationRecord struct {
 	ID            string            `json:"id"`
 	ServiceName   string            `json:"service_name"`
 	GitHubRepo    string            `json:"github_repo"`
+	RepoPath      string            `json:"repo_path,omitempty"`   // scope of the agent's changes in a monorepo
+	BaseBranch    string            `json:"base_branch,omitempty"` // empty uses the repo's default branch
 	ErrorLog      string            `json:"error_log"`
 	Status        RemediationStatus `json:"status"`
 	ContainerID   string            `json:"container_id,omitempty"`
@@ -31,14 +38,59 @@ type RemediationRecord struct {
 	ExitCode      *int64            `json:"exit_code,omitempty"`
 	AgentReport   *AgentReport      `json:"agent_report,omitempty"`
 	ErrorMessage  string            `json:"error_message,omitempty"`
+
+	// Pull request opened for the fix branch
+	PRNumber int    `json:"pr_number,omitempty"`
+	PRURL    string `json:"pr_url,omitempty"`
+	PRError  string `json:"pr_error,omitempty"`
+
+	// Pull request lifecycle and post-merge verification
+	PRState       PRState         `json:"pr_state,omitempty"`
+	MergedAt      *time.Time      `json:"merged_at,omitempty"`
+	StatusAtMerge ServiceStatus   `json:"status_at_merge,omitempty"`
+	Verification  FixVerification `json:"verification,omitempty"`
+	VerifiedAt    *time.Time      `json:"verified_at,omitempty"`
+
+	// Policy that started the remediation
+	Mode           RemediationMode     `json:"mode,omitempty"`
+	ErrorSignature string              `json:"error_signature,omitempty"`
+	Agent          *AgentSelection     `json:"agent,omitempty"`  // requested agent; nil uses the default
+	Prompt         string              `json:"prompt,omitempty"` // extra instructions for the agent
+	TestPolicy     *TestPolicy         `json:"test_policy,omitempty"`
+	Context        *RemediationContext `json:"-"` // persisted apart, served by GET /api/remediations/{id}
+	TriggeredBy    string              `json:"triggered_by,omitempty"`
+	RetryOf        string              `json:"retry_of,omitempty"`
+
+	// Queue state. Pending records are run highest priority first, then oldest first.
+	Priority      int        `json:"priority"`
+	QueuePosition int        `json:"queue_position,omitempty"` // 1-based, pending records only
+	StartedAt     *time.Time `json:"started_at,omitempty"`     // when a worker picked it up
+
+	// Agent provider and model that ran the remediation
+	AgentProvider string `json:"agent_provider,omitempty"`
+	AgentModel    string `json:"agent_model,omitempty"`
+
+	// Change committed by the agent
+	Diff *RemediationDiff `json:"diff,omitempty"`
+
+	// Phases the agent reported reaching, in order
+	Phases []PhaseEvent `json:"phases,omitempty"`
+
+	// ReportSecret signs the agent's callbacks. Never serialized.
+	ReportSecret string `json:"-"`
 }
 
 // AgentReport is what the OpenCode agent sends back after completing
 type AgentReport struct {
+	Version       int       `json:"version"` // agentProtocolVersion
 	RemediationID string    `json:"remediation_id"`
 	Success       bool      `json:"success"`
 	Summary       string    `json:"summary"`
 	FilesChanged  []string  `json:"files_changed,omitempty"`
+	DiffBase64    string    `json:"diff_base64,omitempty"` // unified diff, cut at maxDiffBytes
+	DiffBytes     int       `json:"diff_bytes,omitempty"`  // size of the full diff
+	DiffTruncated bool      `json:"diff_truncated,omitempty"`
+	Tests         *TestRun  `json:"tests,omitempty"`
 	CommitHash    string    `json:"commit_hash,omitempty"`
 	CommitMessage string    `json:"commit_message,omitempty"`
 	Pushed        bool      `json:"pushed"`
@@ -52,41 +104,309 @@ type RemediationStore struct {
 	mu      sync.RWMutex
 	records map[string]*RemediationRecord
 	order   []string // Track insertion order for listing
+	storage Storage
+
+	seenSignatures map[string]time.Time // accepted callback signatures, for replay protection
 }
 
-// NewRemediationStore creates a new remediation store
-func NewRemediationStore() *RemediationStore {
-	return &RemediationStore{
+// NewRemediationStore creates a new remediation store and reloads persisted records
+func NewRemediationStore(storage Storage) *RemediationStore {
+	s := &RemediationStore{
 		records: make(map[string]*RemediationRecord),
 		order:   make([]string, 0),
+		storage: storage,
+
+		seenSignatures: make(map[string]time.Time),
+	}
+
+	loaded := make([]*RemediationRecord, 0)
+	err := storage.Load(bucketRemediations, func(key string, data []byte) error {
+		var record RemediationRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			slog.Warn("Skipping unreadable stored remediation", "id", key, "error", err)
+			return nil
+		}
+		loaded = append(loaded, &record)
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to load remediations from storage", "error", err)
+	}
+
+	// Restore insertion order from start times
+	sort.Slice(loaded, func(i, j int) bool {
+		return loaded[i].StartTime.Before(loaded[j].StartTime)
+	})
+	for _, record := range loaded {
+		// Queued remediations stay pending and are picked up by the workers;
+		// running ones are settled by reconcileRemediations
+		s.records[record.ID] = record
+		s.order = append(s.order, record.ID)
+	}
+
+	// Entries without a matching record are deleted once loading is done:
+	// writing inside a Bolt read transaction can deadlock
+	var staleSecrets, staleDiffs, staleContexts []string
+
+	// A job re-attached after a restart still signs its report with its secret
+	err = storage.Load(bucketReportSecrets, func(key string, data []byte) error {
+		var secret string
+		if err := json.Unmarshal(data, &secret); err != nil {
+			return nil
+		}
+		if record, exists := s.records[key]; exists && record.Status == RemediationRunning {
+			record.ReportSecret = secret
+		} else {
+			staleSecrets = append(staleSecrets, key)
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to load report secrets from storage", "error", err)
+	}
+
+	err = storage.Load(bucketDiffs, func(key string, data []byte) error {
+		var patch string
+		if err := json.Unmarshal(data, &patch); err != nil {
+			return nil
+		}
+		if record, exists := s.records[key]; exists && record.Diff != nil {
+			record.Diff.Patch = patch
+		} else {
+			staleDiffs = append(staleDiffs, key)
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to load remediation diffs from storage", "error", err)
+	}
+
+	err = storage.Load(bucketContexts, func(key string, data []byte) error {
+		var bundle RemediationContext
+		if err := json.Unmarshal(data, &bundle); err != nil {
+			return nil
+		}
+		if record, exists := s.records[key]; exists {
+			record.Context = &bundle
+		} else {
+			staleContexts = append(staleContexts, key)
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to load remediation contexts from storage", "error", err)
+	}
+
+	for _, key := range staleSecrets {
+		storage.Delete(bucketReportSecrets, key)
+	}
+	for _, key := range staleDiffs {
+		storage.Delete(bucketDiffs, key)
+	}
+	for _, key := range staleContexts {
+		storage.Delete(bucketContexts, key)
+	}
+
+	slog.Info("Remediations loaded from storage", "count", len(s.order))
+	return s
+}
+
+// persist writes a record to storage. Must be called with s.mu held.
+func (s *RemediationStore) persist(record *RemediationRecord) {
+	if err := s.storage.Put(bucketRemediations, record.ID, record); err != nil {
+		slog.Error("Failed to persist remediation", "id", record.ID, "error", err)
+	}
+}
+
+// finish persists a record that reached a final status and drops its stored
+// report secret. Must be called with s.mu held.
+func (s *RemediationStore) finish(record *RemediationRecord) {
+	s.persist(record)
+	if record.ReportSecret != "" {
+		s.storage.Delete(bucketReportSecrets, record.ID)
 	}
 }
 
 // Create starts a new remediation record
-func (s *RemediationStore) Create(id, serviceName, githubRepo, errorLog string) *RemediationRecord {
+func (s *RemediationStore) Create(req RemediationRequest) *RemediationRecord {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	record := &RemediationRecord{
-		ID:          id,
-		ServiceName: serviceName,
-		GitHubRepo:  githubRepo,
-		ErrorLog:    errorLog,
-		Status:      RemediationPending,
-		StartTime:   time.Now(),
+		ID:             req.ID,
+		ServiceName:    req.ServiceName,
+		GitHubRepo:     req.GitHubRepo,
+		RepoPath:       req.RepoPath,
+		BaseBranch:     req.BaseBranch,
+		ErrorLog:       req.ErrorLog,
+		Status:         RemediationPending,
+		StartTime:      time.Now(),
+		Mode:           req.Mode,
+		ErrorSignature: req.Signature,
+		Agent:          req.Agent,
+		Priority:       req.Priority,
+		Prompt:         req.Prompt,
+		TestPolicy:     req.TestPolicy,
+		Context:        req.Context,
+		TriggeredBy:    req.TriggeredBy,
+		RetryOf:        req.RetryOf,
 	}
 
-	s.records[id] = record
-	s.order = append(s.order, id)
+	s.records[req.ID] = record
+	s.order = append(s.order, req.ID)
+	s.persist(record)
+	if record.Context != nil {
+		if err := s.storage.Put(bucketContexts, record.ID, record.Context); err != nil {
+			slog.Error("Failed to persist remediation context", "id", record.ID, "error", err)
+		}
+	}
 
-	// Keep only last 100 records
-	if len(s.order) > 100 {
-		oldID := s.order[0]
-		delete(s.records, oldID)
-		s.order = s.order[1:]
+	// Keep only last 100 records, never dropping queued or running ones
+	for i := 0; len(s.order) > 100 && i < len(s.order); i++ {
+		oldID := s.order[i]
+		if status := s.records[oldID].Status; status == RemediationPending || status == RemediationRunning {
+			continue
+		}
+		s.remove(oldID)
+		i--
 	}
 
-	return record
+	copy := *record
+	return &copy
+}
+
+// remove deletes a record. Must be called with s.mu held.
+func (s *RemediationStore) remove(id string) {
+	delete(s.records, id)
+	for i, orderID := range s.order {
+		if orderID == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+	if err := s.storage.Delete(bucketRemediations, id); err != nil {
+		slog.Error("Failed to delete remediation from storage", "id", id, "error", err)
+	}
+	s.storage.Delete(bucketDiffs, id)
+	s.storage.Delete(bucketContexts, id)
+}
+
+// queued returns pending records in run order. Must be called with s.mu held.
+func (s *RemediationStore) queued() []*RemediationRecord {
+	queue := make([]*RemediationRecord, 0)
+	for _, id := range s.order {
+		if record := s.records[id]; record.Status == RemediationPending {
+			queue = append(queue, record)
+		}
+	}
+	sort.SliceStable(queue, func(i, j int) bool {
+		return queue[i].Priority > queue[j].Priority
+	})
+	return queue
+}
+
+// withQueuePositions returns copies of records with QueuePosition filled in.
+// Must be called with s.mu held.
+func (s *RemediationStore) withQueuePositions(records []RemediationRecord) []RemediationRecord {
+	positions := make(map[string]int)
+	for i, record := range s.queued() {
+		positions[record.ID] = i + 1
+	}
+	for i := range records {
+		records[i].QueuePosition = positions[records[i].ID]
+	}
+	return records
+}
+
+// ClaimNext marks the first queued record as running and returns it.
+// Returns false if the queue is empty.
+func (s *RemediationStore) ClaimNext() (*RemediationRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	queue := s.queued()
+	if len(queue) == 0 {
+		return nil, false
+	}
+
+	record := queue[0]
+	now := time.Now()
+	record.Status = RemediationRunning
+	record.StartedAt = &now
+	s.persist(record)
+
+	copy := *record
+	return &copy, true
+}
+
+// CancelQueued cancels a remediation that has not started yet.
+// Returns false if the record does not exist or is no longer queued.
+func (s *RemediationStore) CancelQueued(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, exists := s.records[id]
+	if !exists || record.Status != RemediationPending {
+		return false
+	}
+	now := time.Now()
+	record.Status = RemediationCancelled
+	record.EndTime = &now
+	record.ErrorMessage = "Cancelled before it started"
+	s.persist(record)
+	return true
+}
+
+// SetPriority changes the priority of a queued record.
+// Returns false if the record does not exist or is no longer queued.
+func (s *RemediationStore) SetPriority(id string, priority int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, exists := s.records[id]
+	if !exists || record.Status != RemediationPending {
+		return false
+	}
+	record.Priority = priority
+	s.persist(record)
+	return true
+}
+
+// Drop removes a queued record. Returns false if the record does not exist or
+// is no longer queued.
+func (s *RemediationStore) Drop(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, exists := s.records[id]
+	if !exists || record.Status != RemediationPending {
+		return false
+	}
+	s.remove(id)
+	return true
+}
+
+// Queue returns the queued records in run order
+func (s *RemediationStore) Queue() []RemediationRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	queue := s.queued()
+	records := make([]RemediationRecord, len(queue))
+	for i, record := range queue {
+		records[i] = *record
+		records[i].QueuePosition = i + 1
+	}
+	return records
+}
+
+// runDuration is how long the remediation has been running
+func (r *RemediationRecord) runDuration(now time.Time) string {
+	start := r.StartTime
+	if r.StartedAt != nil {
+		start = *r.StartedAt
+	}
+	return now.Sub(start).Round(time.Second).String()
 }
 
 // UpdateStatus updates the status of a remediation
@@ -102,6 +422,7 @@ func (s *RemediationStore) UpdateStatus(id string, status RemediationStatus, con
 		if containerName != "" {
 			record.ContainerName = containerName
 		}
+		s.persist(record)
 	}
 }
 
@@ -113,7 +434,7 @@ func (s *RemediationStore) Complete(id string, success bool, exitCode int64, err
 	if record, exists := s.records[id]; exists {
 		now := time.Now()
 		record.EndTime = &now
-		record.Duration = now.Sub(record.StartTime).Round(time.Second).String()
+		record.Duration = record.runDuration(now)
 		record.ExitCode = &exitCode
 		record.ErrorMessage = errorMsg
 
@@ -122,6 +443,7 @@ func (s *RemediationStore) Complete(id string, success bool, exitCode int64, err
 		} else {
 			record.Status = RemediationFailed
 		}
+		s.finish(record)
 	}
 }
 
@@ -133,22 +455,235 @@ func (s *RemediationStore) SetTimedOut(id string) {
 	if record, exists := s.records[id]; exists {
 		now := time.Now()
 		record.EndTime = &now
-		record.Duration = now.Sub(record.StartTime).Round(time.Second).String()
+		record.Duration = record.runDuration(now)
 		record.Status = RemediationTimedOut
 		record.ErrorMessage = "Remediation timed out after 10 minutes"
+		s.finish(record)
+	}
+}
+
+// SetCancelled marks a running remediation as cancelled
+func (s *RemediationStore) SetCancelled(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, exists := s.records[id]; exists {
+		now := time.Now()
+		record.EndTime = &now
+		record.Duration = record.runDuration(now)
+		record.Status = RemediationCancelled
+		record.ErrorMessage = "Cancelled"
+		s.finish(record)
+	}
+}
+
+// SetInterrupted fails a remediation whose job was lost, e.g. to a backend restart
+func (s *RemediationStore) SetInterrupted(id, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, exists := s.records[id]; exists {
+		now := time.Now()
+		record.EndTime = &now
+		record.Duration = record.runDuration(now)
+		record.Status = RemediationFailed
+		record.ErrorMessage = reason
+		s.finish(record)
+	}
+}
+
+// Running returns the remediations whose job is running
+func (s *RemediationStore) Running() []RemediationRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var running []RemediationRecord
+	for _, id := range s.order {
+		if record := s.records[id]; record.Status == RemediationRunning {
+			running = append(running, *record)
+		}
+	}
+	return running
+}
+
+// SetReportSecret stores the secret the agent uses to sign its callbacks
+func (s *RemediationStore) SetReportSecret(id, secret string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, exists := s.records[id]; exists {
+		record.ReportSecret = secret
+		if err := s.storage.Put(bucketReportSecrets, id, secret); err != nil {
+			slog.Error("Failed to persist report secret", "id", id, "error", err)
+		}
 	}
 }
 
-// AddAgentReport adds the report from the OpenCode agent
-func (s *RemediationStore) AddAgentReport(id string, report *AgentReport) bool {
+// SetAgent records which agent provider and model run a remediation
+func (s *RemediationStore) SetAgent(id, provider, model string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if record, exists := s.records[id]; exists {
-		record.AgentReport = report
-		return true
+		record.AgentProvider = provider
+		record.AgentModel = model
+		s.persist(record)
 	}
-	return false
+}
+
+// VerifyCallback checks the signature of an agent callback and rejects replays.
+// An unknown remediation fails like a bad signature, so callers cannot probe for IDs.
+func (s *RemediationStore) VerifyCallback(id, timestamp, signature string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, exists := s.records[id]
+	if !exists || record.ReportSecret == "" {
+		return ErrInvalidSignature
+	}
+
+	now := time.Now()
+	if err := verifyCallback(record.ReportSecret, timestamp, signature, body, now); err != nil {
+		return err
+	}
+
+	// Forget signatures that are too old to pass the timestamp check anyway
+	for sig, seenAt := range s.seenSignatures {
+		if now.Sub(seenAt) > 2*reportMaxSkew {
+			delete(s.seenSignatures, sig)
+		}
+	}
+	if _, seen := s.seenSignatures[signature]; seen {
+		return ErrReplayedReport
+	}
+	s.seenSignatures[signature] = now
+
+	return nil
+}
+
+// AddAgentReport adds the report from the OpenCode agent.
+// Returns false if the remediation does not exist, and ErrReplayedReport if
+// a report was already attached.
+func (s *RemediationStore) AddAgentReport(id string, report *AgentReport) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, exists := s.records[id]
+	if !exists {
+		return false, nil
+	}
+	if record.AgentReport != nil {
+		return true, ErrReplayedReport
+	}
+
+	record.AgentReport = report
+	s.persist(record)
+	return true, nil
+}
+
+// AddPhase appends a progress event to a running remediation's timeline.
+// Returns false if the remediation does not exist.
+func (s *RemediationStore) AddPhase(id string, event PhaseEvent) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, exists := s.records[id]
+	if !exists {
+		return false, nil
+	}
+	if record.Status != RemediationRunning {
+		return true, fmt.Errorf("remediation is %s", record.Status)
+	}
+
+	record.Phases = append(record.Phases, event)
+	s.persist(record)
+	return true, nil
+}
+
+// SetDiff stores the change an agent committed
+func (s *RemediationStore) SetDiff(id string, diff *RemediationDiff) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, exists := s.records[id]
+	if !exists {
+		return
+	}
+	record.Diff = diff
+	s.persist(record)
+	if err := s.storage.Put(bucketDiffs, id, diff.Patch); err != nil {
+		slog.Error("Failed to persist remediation diff", "id", id, "error", err)
+	}
+}
+
+// SetPullRequest records the pull request opened for a remediation, or the
+// error that prevented it
+func (s *RemediationStore) SetPullRequest(id string, number int, url, errMsg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, exists := s.records[id]; exists {
+		record.PRNumber = number
+		record.PRURL = url
+		record.PRError = errMsg
+		if number > 0 {
+			record.PRState = PRStateOpen
+		}
+		s.persist(record)
+	}
+}
+
+// UpdatePRState records a pull request state change. When the PR is merged the
+// service's current status is captured and verification starts.
+// Returns false if nothing changed.
+func (s *RemediationStore) UpdatePRState(id string, state PRState, mergedAt *time.Time, serviceStatus ServiceStatus) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, exists := s.records[id]
+	if !exists || record.PRState == state {
+		return false
+	}
+
+	record.PRState = state
+	if state == PRStateMerged {
+		if mergedAt == nil {
+			now := time.Now()
+			mergedAt = &now
+		}
+		record.MergedAt = mergedAt
+		record.StatusAtMerge = serviceStatus
+		record.Verification = VerificationPending
+	}
+	s.persist(record)
+	return true
+}
+
+// SetVerification records the outcome of post-merge verification
+func (s *RemediationStore) SetVerification(id string, verification FixVerification) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, exists := s.records[id]; exists {
+		now := time.Now()
+		record.Verification = verification
+		record.VerifiedAt = &now
+		s.persist(record)
+	}
+}
+
+// FindByPRURL returns the remediation whose pull request has the given URL
+func (s *RemediationStore) FindByPRURL(prURL string) (*RemediationRecord, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, record := range s.records {
+		if record.PRURL != "" && record.PRURL == prURL {
+			copy := *record
+			return &copy, true
+		}
+	}
+	return nil, false
 }
 
 // Get retrieves a single remediation record
@@ -162,7 +697,7 @@ func (s *RemediationStore) Get(id string) (*RemediationRecord, bool) {
 	}
 
 	// Return a copy
-	copy := *record
+	copy := s.withQueuePositions([]RemediationRecord{*record})[0]
 	return &copy, true
 }
 
@@ -178,7 +713,7 @@ func (s *RemediationStore) GetAll() []RemediationRecord {
 			records = append(records, *record)
 		}
 	}
-	return records
+	return s.withQueuePositions(records)
 }
 
 // GetByService returns remediation records for a specific service
@@ -194,5 +729,5 @@ func (s *RemediationStore) GetByService(serviceName string) []RemediationRecord
 			}
 		}
 	}
-	return records
+	return s.withQueuePositions(records)
 }
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// agentProtocolVersion is the version of the report and progress messages the
+// agent wrapper script sends. Bump it when their fields change.
+const agentProtocolVersion = 2
+
+// AgentPhase is a step of an agent run
+type AgentPhase string
+
+const (
+	PhaseCloned    AgentPhase = "cloned"
+	PhaseAnalyzing AgentPhase = "analyzing"
+	PhaseEdited    AgentPhase = "edited"
+	PhaseTestsRun  AgentPhase = "tests_run"
+	PhaseCommitted AgentPhase = "committed"
+	PhasePushed    AgentPhase = "pushed"
+)
+
+var agentPhases = map[AgentPhase]bool{
+	PhaseCloned:    true,
+	PhaseAnalyzing: true,
+	PhaseEdited:    true,
+	PhaseTestsRun:  true,
+	PhaseCommitted: true,
+	PhasePushed:    true,
+}
+
+// PhaseEvent is one entry of a remediation's phase timeline
+type PhaseEvent struct {
+	Phase  AgentPhase `json:"phase"`
+	Time   time.Time  `json:"time"`
+	Detail string     `json:"detail,omitempty"`
+}
+
+// AgentProgress is what the agent posts to /api/remediation/progress when it
+// reaches a phase
+type AgentProgress struct {
+	Version       int        `json:"version"`
+	RemediationID string     `json:"remediation_id"`
+	Phase         AgentPhase `json:"phase"`
+	Detail        string     `json:"detail,omitempty"`
+}
+
+// TestResult is the outcome of the test command run on a fix
+type TestResult string
+
+const (
+	TestsPassed  TestResult = "passed"
+	TestsFailed  TestResult = "failed"
+	TestsSkipped TestResult = "skipped" // no test command, or nothing to test
+)
+
+// maxTestOutputBytes caps the test output kept in a report; the agent sends the tail
+const maxTestOutputBytes = 8 << 10
+
+// TestRun is the result of running the service's tests on the agent's fix
+type TestRun struct {
+	Command      string     `json:"command,omitempty"`
+	Result       TestResult `json:"result"`
+	ExitCode     int        `json:"exit_code"`
+	Output       string     `json:"output,omitempty"`        // tail of the output
+	OutputBase64 string     `json:"output_base64,omitempty"` // as sent by the agent; moved to Output
+}
+
+const (
+	maxReportSummary = 2000
+	maxReportLogs    = 64 << 10
+	maxPhaseDetail   = 500
+	maxReportFiles   = 1000
+)
+
+var commitHashPattern = regexp.MustCompile(`^[0-9a-f]{7,64}$`)
+
+// decodeAgentMessage strictly decodes a report or progress body: unknown
+// fields are rejected so protocol drift is caught early
+func decodeAgentMessage(body []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return nil
+}
+
+// checkProtocolVersion rejects messages from an agent speaking another version
+func checkProtocolVersion(version int) error {
+	if version != agentProtocolVersion {
+		return fmt.Errorf("unsupported protocol version %d (expected %d)", version, agentProtocolVersion)
+	}
+	return nil
+}
+
+// Validate checks a report against the protocol
+func (r *AgentReport) Validate() error {
+	if err := checkProtocolVersion(r.Version); err != nil {
+		return err
+	}
+	if r.RemediationID == "" {
+		return fmt.Errorf("remediation_id is required")
+	}
+	if r.Summary == "" {
+		return fmt.Errorf("summary is required")
+	}
+	if len(r.Summary) > maxReportSummary {
+		return fmt.Errorf("summary exceeds %d bytes", maxReportSummary)
+	}
+	if len(r.Logs) > maxReportLogs {
+		return fmt.Errorf("logs exceed %d bytes", maxReportLogs)
+	}
+	if r.CommitHash != "" && !commitHashPattern.MatchString(r.CommitHash) {
+		return fmt.Errorf("commit_hash must be a hex object name")
+	}
+	if r.Pushed && r.CommitHash == "" {
+		return fmt.Errorf("pushed reports must include commit_hash")
+	}
+	if len(r.FilesChanged) > maxReportFiles {
+		return fmt.Errorf("files_changed exceeds %d entries", maxReportFiles)
+	}
+	for _, file := range r.FilesChanged {
+		if file == "" {
+			return fmt.Errorf("files_changed must not contain empty paths")
+		}
+	}
+	if r.DiffBytes < 0 {
+		return fmt.Errorf("diff_bytes must not be negative")
+	}
+	if r.Tests != nil {
+		switch r.Tests.Result {
+		case TestsPassed, TestsFailed, TestsSkipped:
+		default:
+			return fmt.Errorf("tests.result must be %q, %q or %q", TestsPassed, TestsFailed, TestsSkipped)
+		}
+		if r.Tests.Output != "" {
+			return fmt.Errorf("tests.output is set by the server; send tests.output_base64")
+		}
+		output, err := base64.StdEncoding.DecodeString(r.Tests.OutputBase64)
+		if err != nil {
+			return fmt.Errorf("invalid tests.output_base64: %w", err)
+		}
+		if len(output) > maxTestOutputBytes {
+			return fmt.Errorf("tests output exceeds %d bytes", maxTestOutputBytes)
+		}
+	}
+	return nil
+}
+
+// decodeTestOutput moves the test output sent by the agent into Output.
+// The report must have been validated.
+func (r *AgentReport) decodeTestOutput() {
+	if r.Tests == nil || r.Tests.OutputBase64 == "" {
+		return
+	}
+	output, _ := base64.StdEncoding.DecodeString(r.Tests.OutputBase64)
+	r.Tests.Output = string(output)
+	r.Tests.OutputBase64 = ""
+}
+
+// Validate checks a progress event against the protocol
+func (p *AgentProgress) Validate() error {
+	if err := checkProtocolVersion(p.Version); err != nil {
+		return err
+	}
+	if p.RemediationID == "" {
+		return fmt.Errorf("remediation_id is required")
+	}
+	if !agentPhases[p.Phase] {
+		return fmt.Errorf("unknown phase %q", p.Phase)
+	}
+	if len(p.Detail) > maxPhaseDetail {
+		return fmt.Errorf("detail exceeds %d bytes", maxPhaseDetail)
+	}
+	return nil
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"log/slog"
+	"strings"
 	"sync"
 	"time"
 )
@@ -13,6 +16,7 @@ const (
 	StatusHealthy ServiceStatus = "healthy"
 	StatusError   ServiceStatus = "error"
 	StatusDown    ServiceStatus = "down"
+	StatusPending ServiceStatus = "pending" // registered but no heartbeat received yet
 )
 
 // LogEntry represents a single log entry for a service
@@ -31,25 +35,178 @@ type Service struct {
 	Status         ServiceStatus `json:"status"`
 	LastHeartbeat  time.Time     `json:"last_heartbeat"`
 	LastError      string        `json:"last_error,omitempty"`
-	UptimePercent  float64       `json:"uptime_percent"`
-	TotalChecks    int64         `json:"total_checks"`
-	SuccessChecks  int64         `json:"success_checks"`
+	Commit         string        `json:"commit,omitempty"`
+	UptimePercent  float64       `json:"uptime_percent"` // rolling 24h uptime
+	TotalChecks    int64         `json:"total_checks"`   // heartbeats received
+	SuccessChecks  int64         `json:"success_checks"` // healthy heartbeats received
 	RemediationLog []string      `json:"remediation_log,omitempty"`
 	Logs           []LogEntry    `json:"logs,omitempty"`
+	Check          *CheckConfig  `json:"check,omitempty"` // nil uses the global HEARTBEAT_TIMEOUT
+
+	// Declared metadata for registered services
+	Registered        bool               `json:"registered"`
+	RegisteredAt      *time.Time         `json:"registered_at,omitempty"`
+	RepoPath          string             `json:"repo_path,omitempty"`   // service's directory in a monorepo
+	BaseBranch        string             `json:"base_branch,omitempty"` // branch fixes start from; empty uses the default
+	Owner             string             `json:"owner,omitempty"`
+	Tags              []string           `json:"tags,omitempty"`
+	Environment       string             `json:"environment,omitempty"`
+	Description       string             `json:"description,omitempty"`
+	RemediationPolicy *RemediationPolicy `json:"remediation_policy,omitempty"`
+	Probe             *ProbeConfig       `json:"probe,omitempty"` // active check run by the prober
+
+	// Latest remediation policy decision
+	PolicyDecision *PolicyDecision `json:"policy_decision,omitempty"`
+}
+
+// CheckConfig controls when a service without recent heartbeats is marked down
+type CheckConfig struct {
+	ExpectedInterval Duration `json:"expected_interval"`      // how often the service sends heartbeats
+	GracePeriod      Duration `json:"grace_period,omitempty"` // extra slack on top of the missed beats
+	MissedBeats      int      `json:"missed_beats,omitempty"` // missed heartbeats before the service is down (default 1)
+}
+
+// Validate checks that the configuration is usable
+func (c *CheckConfig) Validate() error {
+	if c.ExpectedInterval <= 0 {
+		return fmt.Errorf("check.expected_interval must be positive")
+	}
+	if c.GracePeriod < 0 {
+		return fmt.Errorf("check.grace_period must not be negative")
+	}
+	if c.MissedBeats < 0 {
+		return fmt.Errorf("check.missed_beats must not be negative")
+	}
+	return nil
 }
 
-// ServiceStore manages the in-memory storage of services
+// Timeout returns how long after the last heartbeat the service is considered down
+func (c *CheckConfig) Timeout() time.Duration {
+	missed := c.MissedBeats
+	if missed < 1 {
+		missed = 1
+	}
+	return time.Duration(c.ExpectedInterval)*time.Duration(missed) + time.Duration(c.GracePeriod)
+}
+
+// Duration is a time.Duration that is encoded in JSON as a string such as "30s" or "1d"
+type Duration time.Duration
+
+// MarshalJSON encodes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(formatWindow(time.Duration(d)))
+}
+
+// UnmarshalJSON accepts a duration string or a number of seconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string or number of seconds")
+	}
+	parsed, err := parseWindow(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// ServiceStore manages services, persisting every change to storage
 type ServiceStore struct {
-	mu       sync.RWMutex
-	services map[string]*Service
-	timeout  time.Duration
+	mu                sync.RWMutex
+	services          map[string]*Service
+	history           map[string][]*UptimeBucket // per-service uptime samples, oldest first
+	timeout           time.Duration
+	allowUnregistered bool // accept heartbeats from services that were never registered
+	storage           Storage
+
+	statusHooks []func(StatusChange)
+}
+
+// StatusChange describes a service moving from one status to another
+type StatusChange struct {
+	Service Service
+	From    ServiceStatus
+	To      ServiceStatus
+	Reason  string // error log or timeout message that caused the change
+}
+
+// NewServiceStore creates a new service store and reloads persisted services
+func NewServiceStore(timeout time.Duration, allowUnregistered bool, storage Storage) *ServiceStore {
+	s := &ServiceStore{
+		services:          make(map[string]*Service),
+		history:           make(map[string][]*UptimeBucket),
+		timeout:           timeout,
+		allowUnregistered: allowUnregistered,
+		storage:           storage,
+	}
+
+	err := storage.Load(bucketServices, func(key string, data []byte) error {
+		var service Service
+		if err := json.Unmarshal(data, &service); err != nil {
+			slog.Warn("Skipping unreadable stored service", "service", key, "error", err)
+			return nil
+		}
+		s.services[service.Name] = &service
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to load services from storage", "error", err)
+	}
+
+	s.loadUptimeHistory()
+
+	slog.Info("Services loaded from storage", "count", len(s.services))
+	return s
 }
 
-// NewServiceStore creates a new service store
-func NewServiceStore(timeout time.Duration) *ServiceStore {
-	return &ServiceStore{
-		services: make(map[string]*Service),
-		timeout:  timeout,
+// timeoutFor returns the heartbeat timeout for a service
+func (s *ServiceStore) timeoutFor(service *Service) time.Duration {
+	if service.Check != nil {
+		return service.Check.Timeout()
+	}
+	// Probed services report at the probe interval - allow a few missed probes
+	if service.Probe != nil && 3*service.Probe.interval() > s.timeout {
+		return 3 * service.Probe.interval()
+	}
+	return s.timeout
+}
+
+// OnStatusChange registers a hook that is called whenever a service changes status.
+// Hooks run with the store locked and must not call back into the ServiceStore.
+func (s *ServiceStore) OnStatusChange(hook func(StatusChange)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.statusHooks = append(s.statusHooks, hook)
+}
+
+// notifyStatusChange runs the status hooks. Must be called with s.mu held.
+func (s *ServiceStore) notifyStatusChange(service *Service, from ServiceStatus, reason string) {
+	if from == service.Status {
+		return
+	}
+	change := StatusChange{
+		Service: *service,
+		From:    from,
+		To:      service.Status,
+		Reason:  reason,
+	}
+	for _, hook := range s.statusHooks {
+		hook(change)
+	}
+}
+
+// persist writes a service to storage. Must be called with s.mu held.
+func (s *ServiceStore) persist(service *Service) {
+	if err := s.storage.Put(bucketServices, service.Name, service); err != nil {
+		slog.Error("Failed to persist service", "service", service.Name, "error", err)
 	}
 }
 
@@ -61,19 +218,25 @@ type LogData struct {
 
 // HeartbeatRequest represents an incoming heartbeat from a service
 type HeartbeatRequest struct {
-	ServiceName string   `json:"service_name"`
-	GitHubRepo  string   `json:"github_repo"`
-	Status      string   `json:"status"`
-	ErrorLog    string   `json:"error_log,omitempty"`
-	LogData     *LogData `json:"log_data,omitempty"` // structured log data
+	ServiceName string       `json:"service_name"`
+	GitHubRepo  string       `json:"github_repo"`
+	Status      string       `json:"status"`
+	ErrorLog    string       `json:"error_log,omitempty"`
+	Commit      string       `json:"commit,omitempty"`   // deployed commit SHA
+	LogData     *LogData     `json:"log_data,omitempty"` // structured log data
+	Check       *CheckConfig `json:"check,omitempty"`    // per-service timeout settings
 }
 
-// RecordHeartbeat records a heartbeat for a service
-func (s *ServiceStore) RecordHeartbeat(req HeartbeatRequest) *Service {
+// RecordHeartbeat records a heartbeat for a service.
+// Returns ErrServiceNotRegistered for unknown services if unregistered heartbeats are disabled.
+func (s *ServiceStore) RecordHeartbeat(req HeartbeatRequest) (*Service, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	service, exists := s.services[req.ServiceName]
+	if !exists && !s.allowUnregistered {
+		return nil, ErrServiceNotRegistered
+	}
 	if !exists {
 		service = &Service{
 			Name:          req.ServiceName,
@@ -85,8 +248,18 @@ func (s *ServiceStore) RecordHeartbeat(req HeartbeatRequest) *Service {
 		s.services[req.ServiceName] = service
 	}
 
-	// Update service info
-	service.GitHubRepo = req.GitHubRepo
+	previousStatus := service.Status
+
+	// Update service info - declared metadata of registered services wins
+	if !service.Registered || service.GitHubRepo == "" {
+		service.GitHubRepo = req.GitHubRepo
+	}
+	if req.Check != nil && (!service.Registered || service.Check == nil) {
+		service.Check = req.Check
+	}
+	if req.Commit != "" {
+		service.Commit = req.Commit
+	}
 	service.LastHeartbeat = time.Now()
 	service.TotalChecks++
 
@@ -143,12 +316,14 @@ func (s *ServiceStore) RecordHeartbeat(req HeartbeatRequest) *Service {
 		})
 	}
 
-	// Calculate uptime percentage
-	if service.TotalChecks > 0 {
-		service.UptimePercent = float64(service.SuccessChecks) / float64(service.TotalChecks) * 100
-	}
+	service.UptimePercent = s.currentUptime(service, now)
+
+	s.persist(service)
+	s.notifyStatusChange(service, previousStatus, service.LastError)
 
-	return service
+	// Return a copy to avoid race conditions
+	copy := *service
+	return &copy, nil
 }
 
 // addLog adds a log entry and keeps only the last 100 entries
@@ -197,6 +372,21 @@ func buildLogMessage(data *LogData) string {
 		}
 		return "File upload failed"
 		
+	case "probe":
+		probeType, _ := data.Details["probe_type"].(string)
+		target, _ := data.Details["target"].(string)
+		// int64 from the prober, float64 once the details went through JSON
+		var latency int64
+		switch v := data.Details["latency_ms"].(type) {
+		case int64:
+			latency = v
+		case int:
+			latency = int64(v)
+		case float64:
+			latency = int64(v)
+		}
+		return fmt.Sprintf("%s probe of %s succeeded (%dms)", strings.ToUpper(probeType), target, latency)
+		
 	default:
 		return fmt.Sprintf("Event: %s", data.EventType)
 	}
@@ -236,7 +426,8 @@ func (s *ServiceStore) CheckTimeouts() []*Service {
 	return downServices
 }
 
-// CheckTimeoutsAndUpdateUptime checks for timed out services AND updates uptime for all unhealthy services
+// CheckTimeoutsAndUpdateUptime checks for timed out services AND samples every service's
+// status into its uptime history
 // Returns: (newly down services, all services that were updated)
 func (s *ServiceStore) CheckTimeoutsAndUpdateUptime() ([]*Service, []*Service) {
 	s.mu.Lock()
@@ -249,10 +440,16 @@ func (s *ServiceStore) CheckTimeoutsAndUpdateUptime() ([]*Service, []*Service) {
 	for _, service := range s.services {
 		wasUpdated := false
 		
+		// Registered services that never sent a heartbeat time out from registration
+		lastSeen := service.LastHeartbeat
+		if lastSeen.IsZero() && service.RegisteredAt != nil {
+			lastSeen = *service.RegisteredAt
+		}
+
 		// Check if service just timed out
-		if service.Status != StatusDown && now.Sub(service.LastHeartbeat) > s.timeout {
+		if service.Status != StatusDown && now.Sub(lastSeen) > s.timeoutFor(service) {
+			previousStatus := service.Status
 			service.Status = StatusDown
-			service.TotalChecks++
 			// Add status change log
 			service.addLog(LogEntry{
 				Timestamp: now,
@@ -260,17 +457,21 @@ func (s *ServiceStore) CheckTimeoutsAndUpdateUptime() ([]*Service, []*Service) {
 				Message:   "Service marked as DOWN - heartbeat timeout",
 			})
 			newlyDownServices = append(newlyDownServices, service)
+			s.notifyStatusChange(service, previousStatus, "Service heartbeat timeout - no response received")
 			wasUpdated = true
-		} else if service.Status == StatusDown || service.Status == StatusError {
-			// For services that are down or in error state, continue incrementing checks
-			// This makes uptime percentage continuously decrease while service is unhealthy
-			service.TotalChecks++
+		}
+
+		// Sample the current status once per tick for the uptime history
+		s.recordSample(service, now)
+
+		// Recalculate rolling uptime percentage
+		if uptime := s.currentUptime(service, now); uptime != service.UptimePercent {
+			service.UptimePercent = uptime
 			wasUpdated = true
 		}
-		
-		// Recalculate uptime percentage
-		if wasUpdated && service.TotalChecks > 0 {
-			service.UptimePercent = float64(service.SuccessChecks) / float64(service.TotalChecks) * 100
+
+		if wasUpdated {
+			s.persist(service)
 			
 			// Make a copy for the updated list
 			serviceCopy := *service
@@ -299,5 +500,7 @@ func (s *ServiceStore) AddRemediationLog(serviceName string, log string) {
 			Type:      "remediation",
 			Message:   log,
 		})
+
+		s.persist(service)
 	}
 }
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Storage buckets used by the stores
+const (
+	bucketServices      = "services"
+	bucketRemediations  = "remediations"
+	bucketReportSecrets = "report_secrets" // report secrets of running remediations
+	bucketDiffs         = "remediation_diffs"
+	bucketContexts      = "remediation_contexts"
+	bucketUptime        = "uptime"
+	bucketIncidents     = "incidents"
+	bucketTokens        = "tokens"
+	bucketAllowedRepos  = "allowed_repos"
+)
+
+// Storage persists store state so it survives backend restarts.
+// Values are grouped into named buckets and encoded as JSON.
+type Storage interface {
+	Put(bucket, key string, value interface{}) error
+	Delete(bucket, key string) error
+	// Load calls fn for every key/value pair in the bucket
+	Load(bucket string, fn func(key string, data []byte) error) error
+	Close() error
+}
+
+// NewStorageFromEnv opens the storage backend configured by DB_PATH.
+// Storage is in-memory only when DB_PATH is ":memory:".
+func NewStorageFromEnv() (Storage, error) {
+	path := os.Getenv("DB_PATH")
+	if path == "" {
+		path = "./data/highline.db"
+	}
+	if path == ":memory:" {
+		slog.Info("Using in-memory storage - state will not survive restarts")
+		return NewMemoryStorage(), nil
+	}
+
+	storage, err := NewBoltStorage(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
+
+	slog.Info("Storage initialized", "backend", "bolt", "path", path)
+	return storage, nil
+}
+
+// BoltStorage is an embedded on-disk Storage backed by BoltDB
+type BoltStorage struct {
+	db *bolt.DB
+}
+
+// NewBoltStorage opens (or creates) a BoltDB database at path
+func NewBoltStorage(path string) (*BoltStorage, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create data directory: %w", err)
+		}
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return &BoltStorage{db: db}, nil
+}
+
+// Put stores value under key in the given bucket
+func (b *BoltStorage) Put(bucket, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s/%s: %w", bucket, key, err)
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bkt, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return bkt.Put([]byte(key), data)
+	})
+}
+
+// Delete removes key from the given bucket
+func (b *BoltStorage) Delete(bucket, key string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return nil
+		}
+		return bkt.Delete([]byte(key))
+	})
+}
+
+// Load iterates over every entry in the given bucket
+func (b *BoltStorage) Load(bucket string, fn func(key string, data []byte) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return nil
+		}
+		return bkt.ForEach(func(k, v []byte) error {
+			return fn(string(k), v)
+		})
+	})
+}
+
+// Close closes the underlying database
+func (b *BoltStorage) Close() error {
+	return b.db.Close()
+}
+
+// MemoryStorage is a Storage that keeps everything in memory.
+// Used when persistence is disabled or the database cannot be opened.
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string][]byte
+}
+
+// NewMemoryStorage creates a new in-memory storage
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		buckets: make(map[string]map[string][]byte),
+	}
+}
+
+// Put stores value under key in the given bucket
+func (m *MemoryStorage) Put(bucket, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s/%s: %w", bucket, key, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.buckets[bucket] == nil {
+		m.buckets[bucket] = make(map[string][]byte)
+	}
+	m.buckets[bucket][key] = data
+	return nil
+}
+
+// Delete removes key from the given bucket
+func (m *MemoryStorage) Delete(bucket, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.buckets[bucket], key)
+	return nil
+}
+
+// Load iterates over every entry in the given bucket in key order
+func (m *MemoryStorage) Load(bucket string, fn func(key string, data []byte) error) error {
+	m.mu.RLock()
+	keys := make([]string, 0, len(m.buckets[bucket]))
+	for k := range m.buckets[bucket] {
+		keys = append(keys, k)
+	}
+	values := make(map[string][]byte, len(keys))
+	for _, k := range keys {
+		values[k] = m.buckets[bucket][k]
+	}
+	m.mu.RUnlock()
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := fn(k, values[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close is a no-op for in-memory storage
+func (m *MemoryStorage) Close() error {
+	return nil
+}
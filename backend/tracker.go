@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// PRState is the lifecycle state of a fix pull request
+type PRState string
+
+const (
+	PRStateOpen   PRState = "open"
+	PRStateMerged PRState = "merged"
+	PRStateClosed PRState = "closed" // closed without merging
+)
+
+// FixVerification is the observed effect of a merged fix on its service
+type FixVerification string
+
+const (
+	VerificationPending   FixVerification = "pending"      // merged, still watching the service
+	VerificationFixed     FixVerification = "verified_fix" // service healthy with no new incidents
+	VerificationNoEffect  FixVerification = "no_effect"    // the incident open at merge never resolved
+	VerificationRegressed FixVerification = "regressed"    // a new incident opened after the merge
+)
+
+// FixTracker follows fix pull requests until they are merged or closed, then
+// watches the service to verify whether the fix worked
+type FixTracker struct {
+	pollInterval time.Duration
+	verifyWindow time.Duration
+}
+
+// prStateOf maps a GitHub pull request to a PRState
+func prStateOf(pr *PullRequest) PRState {
+	switch {
+	case pr.Merged || pr.MergedAt != nil:
+		return PRStateMerged
+	case pr.State == "closed":
+		return PRStateClosed
+	default:
+		return PRStateOpen
+	}
+}
+
+// runFixTracker polls open pull requests and verifies merged fixes
+func (app *App) runFixTracker(ctx context.Context) {
+	ticker := time.NewTicker(app.tracker.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, record := range app.remediationStore.GetAll() {
+				switch {
+				case record.PRNumber > 0 && record.PRState == PRStateOpen:
+					app.pollPullRequest(ctx, &record)
+				case record.Verification == VerificationPending:
+					app.verifyFix(&record)
+				}
+			}
+		}
+	}
+}
+
+// pollPullRequest fetches the current state of a remediation's pull request
+func (app *App) pollPullRequest(ctx context.Context, record *RemediationRecord) {
+	provider, repo, err := app.gitHosts.Provider(record.GitHubRepo)
+	if err != nil || provider == nil {
+		return
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	pr, err := provider.GetPullRequest(reqCtx, repo, record.PRNumber)
+	if err != nil {
+		slog.Warn("[TRACKER] Failed to poll pull request",
+			"id", record.ID,
+			"pr_number", record.PRNumber,
+			"error", err,
+		)
+		return
+	}
+
+	app.updatePRState(record, prStateOf(pr), pr.MergedAt)
+}
+
+// updatePRState applies a pull request state change from polling or a webhook
+func (app *App) updatePRState(record *RemediationRecord, state PRState, mergedAt *time.Time) {
+	var serviceStatus ServiceStatus
+	if service, ok := app.store.GetService(record.ServiceName); ok {
+		serviceStatus = service.Status
+	}
+
+	if !app.remediationStore.UpdatePRState(record.ID, state, mergedAt, serviceStatus) {
+		return
+	}
+
+	slog.Info("[TRACKER] Pull request state changed",
+		"id", record.ID,
+		"service", record.ServiceName,
+		"pr_url", record.PRURL,
+		"state", state,
+	)
+	app.store.AddRemediationLog(record.ServiceName,
+		time.Now().Format(time.RFC3339)+" - Pull request "+string(state)+": "+record.PRURL)
+
+	app.broadcastRemediation(record.ID)
+}
+
+// verifyFix decides whether a merged fix worked once the verification window
+// has passed:
+//   - regressed: a new incident opened after the merge
+//   - no_effect: the service was unhealthy at merge and never recovered
+//   - verified_fix: otherwise
+func (app *App) verifyFix(record *RemediationRecord) {
+	if record.MergedAt == nil || time.Since(*record.MergedAt) < app.tracker.verifyWindow {
+		return
+	}
+
+	verification := VerificationFixed
+	for _, incident := range app.incidents.List(record.ServiceName, "") {
+		if incident.StartTime.After(*record.MergedAt) {
+			verification = VerificationRegressed
+			break
+		}
+	}
+	if verification == VerificationFixed && isUnhealthy(record.StatusAtMerge) {
+		// With no new incident, still unhealthy means it never recovered
+		if service, ok := app.store.GetService(record.ServiceName); ok && isUnhealthy(service.Status) {
+			verification = VerificationNoEffect
+		}
+	}
+
+	app.remediationStore.SetVerification(record.ID, verification)
+
+	slog.Info("[TRACKER] Fix verification complete",
+		"id", record.ID,
+		"service", record.ServiceName,
+		"verification", verification,
+	)
+	app.store.AddRemediationLog(record.ServiceName,
+		time.Now().Format(time.RFC3339)+" - Fix verification for "+record.ID+": "+string(verification))
+
+	app.broadcastRemediation(record.ID)
+}
+
+// GitHubWebhookHandler receives pull_request events so PR state changes are
+// picked up without waiting for the next poll
+func (app *App) GitHubWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if app.webhookSecret == "" {
+		http.Error(w, "Webhooks not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 5<<20))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	expected := "sha256=" + hmacSHA256Hex(app.webhookSecret, body)
+	if !signatureEqual(expected, r.Header.Get("X-Hub-Signature-256")) {
+		slog.Warn("[TRACKER] Rejected webhook with invalid signature")
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Header.Get("X-GitHub-Event") != "pull_request" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var event struct {
+		Action      string      `json:"action"`
+		PullRequest PullRequest `json:"pull_request"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	record, exists := app.remediationStore.FindByPRURL(event.PullRequest.HTMLURL)
+	if !exists {
+		// Not a Highline pull request
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	app.updatePRState(record, prStateOf(&event.PullRequest), event.PullRequest.MergedAt)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// isUnhealthy reports whether a service status is a failure
+func isUnhealthy(status ServiceStatus) bool {
+	return status == StatusError || status == StatusDown
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// uptimeBucketSize is the base resolution samples are aggregated into
+	uptimeBucketSize = 5 * time.Minute
+	// uptimeRetention is how long uptime history is kept
+	uptimeRetention = 30 * 24 * time.Hour
+	// maxUptimeBuckets caps the number of buckets returned by a single query
+	maxUptimeBuckets = 2000
+)
+
+// UptimeBucket aggregates status samples taken during one base interval
+type UptimeBucket struct {
+	Start   time.Time `json:"start"`
+	Healthy int64     `json:"healthy"`
+	Error   int64     `json:"error"`
+	Down    int64     `json:"down"`
+}
+
+// Total returns the number of samples in the bucket
+func (b *UptimeBucket) Total() int64 {
+	return b.Healthy + b.Error + b.Down
+}
+
+// add records a single status sample in the bucket
+func (b *UptimeBucket) add(status ServiceStatus) {
+	switch status {
+	case StatusHealthy:
+		b.Healthy++
+	case StatusError:
+		b.Error++
+	case StatusDown:
+		b.Down++
+	}
+}
+
+// UptimeHistoryPoint is one bucket of an uptime query at the requested resolution
+type UptimeHistoryPoint struct {
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+	Status        string    `json:"status"` // "healthy", "error", "down" or "no_data"
+	UptimePercent *float64  `json:"uptime_percent,omitempty"`
+	Samples       int64     `json:"samples"`
+}
+
+// UptimeReport is the response for an uptime history query
+type UptimeReport struct {
+	Service       string               `json:"service"`
+	Window        string               `json:"window"`
+	Resolution    string               `json:"resolution"`
+	UptimePercent *float64             `json:"uptime_percent,omitempty"`
+	Rolling       map[string]*float64  `json:"rolling"` // "24h", "7d", "30d"
+	Buckets       []UptimeHistoryPoint `json:"buckets"`
+}
+
+// uptimeKey builds the storage key for a service bucket
+func uptimeKey(serviceName string, start time.Time) string {
+	return fmt.Sprintf("%s|%012d", serviceName, start.Unix())
+}
+
+// loadUptimeHistory reloads persisted uptime buckets. Called from NewServiceStore.
+func (s *ServiceStore) loadUptimeHistory() {
+	err := s.storage.Load(bucketUptime, func(key string, data []byte) error {
+		var bucket UptimeBucket
+		if err := json.Unmarshal(data, &bucket); err != nil {
+			slog.Warn("Skipping unreadable uptime bucket", "key", key, "error", err)
+			return nil
+		}
+		name := key[:strings.LastIndex(key, "|")]
+		s.history[name] = append(s.history[name], &bucket)
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to load uptime history from storage", "error", err)
+	}
+
+	for _, buckets := range s.history {
+		sort.Slice(buckets, func(i, j int) bool {
+			return buckets[i].Start.Before(buckets[j].Start)
+		})
+	}
+}
+
+// recordSample adds a status sample for a service and prunes expired buckets.
+// The current bucket is only kept in memory: it is written to storage when
+// the next one starts, or by FlushUptime. Must be called with s.mu held.
+func (s *ServiceStore) recordSample(service *Service, now time.Time) {
+	start := now.Truncate(uptimeBucketSize)
+	buckets := s.history[service.Name]
+
+	var current *UptimeBucket
+	if n := len(buckets); n > 0 && buckets[n-1].Start.Equal(start) {
+		current = buckets[n-1]
+	} else {
+		if n > 0 {
+			s.persistUptimeBucket(service.Name, buckets[n-1])
+		}
+		current = &UptimeBucket{Start: start}
+		buckets = append(buckets, current)
+	}
+	current.add(service.Status)
+
+	// Drop buckets that fell out of the retention window
+	cutoff := now.Add(-uptimeRetention)
+	expired := 0
+	for expired < len(buckets) && buckets[expired].Start.Before(cutoff) {
+		if err := s.storage.Delete(bucketUptime, uptimeKey(service.Name, buckets[expired].Start)); err != nil {
+			slog.Error("Failed to delete uptime bucket", "service", service.Name, "error", err)
+		}
+		expired++
+	}
+	s.history[service.Name] = buckets[expired:]
+}
+
+// persistUptimeBucket writes one uptime bucket to storage. Must be called
+// with s.mu held.
+func (s *ServiceStore) persistUptimeBucket(name string, bucket *UptimeBucket) {
+	if err := s.storage.Put(bucketUptime, uptimeKey(name, bucket.Start), bucket); err != nil {
+		slog.Error("Failed to persist uptime bucket", "service", name, "error", err)
+	}
+}
+
+// FlushUptime writes every service's current uptime bucket to storage. Called
+// on shutdown; samples since the last flush are lost if the process dies.
+func (s *ServiceStore) FlushUptime() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, buckets := range s.history {
+		if n := len(buckets); n > 0 {
+			s.persistUptimeBucket(name, buckets[n-1])
+		}
+	}
+}
+
+// uptimeSince returns the uptime percentage of the samples taken since from.
+// Returns nil when there are no samples. Must be called with s.mu held.
+func (s *ServiceStore) uptimeSince(name string, from time.Time) *float64 {
+	var healthy, total int64
+	for _, bucket := range s.history[name] {
+		if bucket.Start.Add(uptimeBucketSize).After(from) {
+			healthy += bucket.Healthy
+			total += bucket.Total()
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+	percent := float64(healthy) / float64(total) * 100
+	return &percent
+}
+
+// currentUptime returns the rolling 24h uptime of a service, falling back to its
+// current status before any samples exist. Must be called with s.mu held.
+func (s *ServiceStore) currentUptime(service *Service, now time.Time) float64 {
+	if percent := s.uptimeSince(service.Name, now.Add(-24*time.Hour)); percent != nil {
+		return *percent
+	}
+	if service.Status == StatusHealthy {
+		return 100
+	}
+	return 0
+}
+
+// GetUptime returns the uptime history of a service over window, aggregated into
+// buckets of the given resolution
+func (s *ServiceStore) GetUptime(name string, window, resolution time.Duration) (*UptimeReport, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, exists := s.services[name]; !exists {
+		return nil, false
+	}
+
+	now := time.Now()
+	end := now.Truncate(resolution).Add(resolution)
+	start := end.Add(-window)
+
+	report := &UptimeReport{
+		Service:       name,
+		Window:        formatWindow(window),
+		Resolution:    formatWindow(resolution),
+		UptimePercent: s.uptimeSince(name, now.Add(-window)),
+		Rolling: map[string]*float64{
+			"24h": s.uptimeSince(name, now.Add(-24*time.Hour)),
+			"7d":  s.uptimeSince(name, now.Add(-7*24*time.Hour)),
+			"30d": s.uptimeSince(name, now.Add(-30*24*time.Hour)),
+		},
+		Buckets: make([]UptimeHistoryPoint, 0),
+	}
+
+	history := s.history[name]
+	i := 0
+	for bucketStart := start; bucketStart.Before(end); bucketStart = bucketStart.Add(resolution) {
+		bucketEnd := bucketStart.Add(resolution)
+		var agg UptimeBucket
+		for i < len(history) && history[i].Start.Before(bucketEnd) {
+			if !history[i].Start.Before(bucketStart) {
+				agg.Healthy += history[i].Healthy
+				agg.Error += history[i].Error
+				agg.Down += history[i].Down
+			}
+			i++
+		}
+
+		point := UptimeHistoryPoint{
+			Start:   bucketStart,
+			End:     bucketEnd,
+			Status:  "no_data",
+			Samples: agg.Total(),
+		}
+		if point.Samples > 0 {
+			percent := float64(agg.Healthy) / float64(point.Samples) * 100
+			point.UptimePercent = &percent
+			switch {
+			case agg.Down > 0:
+				point.Status = string(StatusDown)
+			case agg.Error > 0:
+				point.Status = string(StatusError)
+			default:
+				point.Status = string(StatusHealthy)
+			}
+		}
+		report.Buckets = append(report.Buckets, point)
+	}
+
+	return report, true
+}
+
+// parseWindow parses durations like "30m", "24h" or "7d". Zero is accepted
+// and means "use the default", so an unset Duration survives a round trip.
+func parseWindow(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil || days < 0 {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return d, nil
+}
+
+// formatWindow formats a duration the way parseWindow accepts it
+func formatWindow(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return d.String()
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// storedUptime returns the persisted uptime buckets of a service by start time
+func storedUptime(t *testing.T, storage Storage, name string) map[time.Time]UptimeBucket {
+	t.Helper()
+	buckets := make(map[time.Time]UptimeBucket)
+	storage.Load(bucketUptime, func(key string, data []byte) error {
+		var bucket UptimeBucket
+		if err := json.Unmarshal(data, &bucket); err != nil {
+			t.Fatal(err)
+		}
+		if key == uptimeKey(name, bucket.Start) {
+			buckets[bucket.Start.UTC()] = bucket
+		}
+		return nil
+	})
+	return buckets
+}
+
+func TestUptimeSamplesAreBuffered(t *testing.T) {
+	storage := NewMemoryStorage()
+	store := NewServiceStore(time.Minute, true, storage)
+	service := &Service{Name: "api", Status: StatusHealthy}
+	first := time.Now().Truncate(uptimeBucketSize)
+
+	store.mu.Lock()
+	for i := 0; i < 10; i++ {
+		store.recordSample(service, first.Add(time.Duration(i)*time.Second))
+	}
+	store.mu.Unlock()
+	if stored := storedUptime(t, storage, "api"); len(stored) != 0 {
+		t.Fatalf("samples in the current bucket were written: %v", stored)
+	}
+
+	// The first sample of the next bucket writes the finished one
+	store.mu.Lock()
+	store.recordSample(service, first.Add(uptimeBucketSize))
+	store.mu.Unlock()
+	stored := storedUptime(t, storage, "api")
+	if len(stored) != 1 || stored[first.UTC()].Healthy != 10 {
+		t.Fatalf("after rollover stored %v, want the first bucket with 10 samples", stored)
+	}
+
+	store.FlushUptime()
+	if stored := storedUptime(t, storage, "api"); len(stored) != 2 || stored[first.Add(uptimeBucketSize).UTC()].Healthy != 1 {
+		t.Errorf("after flush stored %v, want both buckets", stored)
+	}
+}
+
+func TestDurationZeroRoundTrip(t *testing.T) {
+	var policy struct {
+		Cooldown Duration `json:"cooldown"`
+	}
+	encoded, _ := json.Marshal(policy)
+	if err := json.Unmarshal(encoded, &policy); err != nil || policy.Cooldown != 0 {
+		t.Errorf("decoding %s: %v, cooldown %v", encoded, err, policy.Cooldown)
+	}
+	for _, value := range []string{"0s", "0d"} {
+		if d, err := parseWindow(value); err != nil || d != 0 {
+			t.Errorf("parseWindow(%q) = %v, %v", value, d, err)
+		}
+	}
+	if _, err := parseWindow("-5m"); err == nil {
+		t.Error("parseWindow accepted a negative duration")
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Service names and repo URLs come from heartbeats and end up in agent jobs
+// that hold a push token, so both are restricted to plain characters
+var (
+	serviceNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+	// https://host[:port]/owner/repo[.git], with GitLab subgroups between
+	// owner and repo
+	httpsRepoURLPattern = regexp.MustCompile(`^https://[A-Za-z0-9]([A-Za-z0-9.-]*[A-Za-z0-9])?(:[0-9]{1,5})?/[A-Za-z0-9_.-]+(/[A-Za-z0-9_.-]+)+/?$`)
+	// file:///path/to/repo[.git] for local remotes
+	fileRepoURLPattern = regexp.MustCompile(`^file://(/[A-Za-z0-9_.-]+)+/?$`)
+	// services/api, relative to the repo root
+	repoPathPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+(/[A-Za-z0-9_.-]+)*$`)
+	// main, release/2.x
+	branchNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]{0,199}$`)
+)
+
+// validateServiceName checks that a service name is 1-128 letters, digits,
+// dots, underscores or dashes, starting with a letter or digit
+func validateServiceName(name string) error {
+	if !serviceNamePattern.MatchString(name) {
+		return fmt.Errorf("service name must be 1-128 letters, digits, '.', '_' or '-', starting with a letter or digit")
+	}
+	return nil
+}
+
+// validateRepoURL checks that a repo URL looks like https://host/owner/repo
+// or file:///path/to/repo. Only admins may point a service at a local path,
+// so heartbeats use validateHTTPSRepoURL.
+func validateRepoURL(repo string) error {
+	if !httpsRepoURLPattern.MatchString(repo) && !fileRepoURLPattern.MatchString(repo) {
+		return fmt.Errorf("repo URL must look like https://host/owner/repo or file:///path/to/repo: %q", repo)
+	}
+	return checkRepoURLSegments(repo)
+}
+
+// validateHTTPSRepoURL checks that a repo URL looks like https://host/owner/repo
+func validateHTTPSRepoURL(repo string) error {
+	if !httpsRepoURLPattern.MatchString(repo) {
+		return fmt.Errorf("repo URL must look like https://host/owner/repo: %q", repo)
+	}
+	return checkRepoURLSegments(repo)
+}
+
+// checkRepoURLSegments rejects . and .. in a repo URL's path
+func checkRepoURLSegments(repo string) error {
+	for _, part := range strings.Split(strings.TrimSuffix(repo, "/"), "/")[3:] {
+		if part == "." || part == ".." {
+			return fmt.Errorf("repo URL must not contain . or .. path segments: %q", repo)
+		}
+	}
+	return nil
+}
+
+// validateRepoPath checks that a service's path in its repo is a relative
+// path that stays inside the repo
+func validateRepoPath(repoPath string) error {
+	if !repoPathPattern.MatchString(repoPath) {
+		return fmt.Errorf("repo_path must be a relative path of letters, digits, '.', '_', '-' and '/': %q", repoPath)
+	}
+	for _, part := range strings.Split(repoPath, "/") {
+		if part == "." || part == ".." {
+			return fmt.Errorf("repo_path must not contain . or .. segments: %q", repoPath)
+		}
+	}
+	return nil
+}
+
+// validateBranchName checks that a branch name is plain enough to hand to git
+func validateBranchName(branch string) error {
+	if !branchNamePattern.MatchString(branch) || strings.Contains(branch, "..") || strings.Contains(branch, "//") ||
+		strings.HasSuffix(branch, "/") || strings.HasSuffix(branch, ".") || strings.HasSuffix(branch, ".lock") {
+		return fmt.Errorf("branch must be a valid git branch name of letters, digits, '.', '_', '-' and '/': %q", branch)
+	}
+	return nil
+}
+
+// outOfScope returns the files that are not under repoPath. Every file is in
+// scope when repoPath is empty.
+func outOfScope(repoPath string, files []string) []string {
+	if repoPath == "" {
+		return nil
+	}
+	var outside []string
+	for _, file := range files {
+		if !strings.HasPrefix(file, repoPath+"/") {
+			outside = append(outside, file)
+		}
+	}
+	return outside
+}
@@ -12,20 +12,20 @@ import (
 // WSHub manages all WebSocket connections
 type WSHub struct {
 	mu      sync.RWMutex
-	clients map[*websocket.Conn]bool
+	clients map[*websocket.Conn]map[string]bool // client -> remediation IDs whose logs it follows
 }
 
 // NewWSHub creates a new WebSocket hub
 func NewWSHub() *WSHub {
 	return &WSHub{
-		clients: make(map[*websocket.Conn]bool),
+		clients: make(map[*websocket.Conn]map[string]bool),
 	}
 }
 
 // AddClient registers a new WebSocket client
 func (h *WSHub) AddClient(ws *websocket.Conn) {
 	h.mu.Lock()
-	h.clients[ws] = true
+	h.clients[ws] = make(map[string]bool)
 	h.mu.Unlock()
 	slog.Info("WebSocket client connected", "total_clients", len(h.clients))
 }
@@ -63,6 +63,52 @@ func (h *WSHub) Broadcast(msgType string, data interface{}) {
 	}
 }
 
+// Subscribe makes a client receive the log lines of a remediation
+func (h *WSHub) Subscribe(ws *websocket.Conn, remediationID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if subs, ok := h.clients[ws]; ok {
+		subs[remediationID] = true
+	}
+}
+
+// Unsubscribe stops sending a remediation's log lines to a client
+func (h *WSHub) Unsubscribe(ws *websocket.Conn, remediationID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if subs, ok := h.clients[ws]; ok {
+		delete(subs, remediationID)
+	}
+}
+
+// SendToSubscribers sends a message to the clients subscribed to a remediation
+func (h *WSHub) SendToSubscribers(remediationID, msgType string, data interface{}) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var msgBytes []byte
+	for client, subs := range h.clients {
+		if !subs[remediationID] {
+			continue
+		}
+		if msgBytes == nil {
+			var err error
+			msgBytes, err = json.Marshal(WSMessage{Type: msgType, Data: data})
+			if err != nil {
+				slog.Error("Failed to marshal WebSocket message", "error", err)
+				return
+			}
+		}
+		go func(c *websocket.Conn) {
+			if _, err := c.Write(msgBytes); err != nil {
+				slog.Debug("Failed to send to client", "error", err)
+			}
+		}(client)
+	}
+}
+
 // WSMessage is the structure for WebSocket messages
 type WSMessage struct {
 	Type string      `json:"type"`
@@ -86,27 +132,45 @@ func (app *App) WSHandler(ws *websocket.Conn) {
 	msgBytes, _ := json.Marshal(initialMsg)
 	ws.Write(msgBytes)
 
-	// Keep connection alive and listen for pings
+	// Keep connection alive and listen for pings and log subscriptions
 	buf := make([]byte, 1024)
 	for {
 		n, err := ws.Read(buf)
 		if err != nil {
 			break
 		}
-		// Handle ping/pong or other client messages if needed
 		if n > 0 {
-			var msg map[string]interface{}
-			if json.Unmarshal(buf[:n], &msg) == nil {
-				if msg["type"] == "ping" {
-					pong := WSMessage{Type: "pong", Data: nil}
-					pongBytes, _ := json.Marshal(pong)
-					ws.Write(pongBytes)
-				}
+			var msg WSClientMessage
+			if json.Unmarshal(buf[:n], &msg) != nil {
+				continue
+			}
+			switch msg.Type {
+			case "ping":
+				pong := WSMessage{Type: "pong", Data: nil}
+				pongBytes, _ := json.Marshal(pong)
+				ws.Write(pongBytes)
+			case "subscribe_logs":
+				// Subscribe before replaying so no line is missed; lines that
+				// arrive in both are deduplicated by the client using offsets
+				app.wsHub.Subscribe(ws, msg.ID)
+				replay := WSMessage{Type: "remediation_log", Data: app.remediationLogs.Since(msg.ID, msg.Offset)}
+				replayBytes, _ := json.Marshal(replay)
+				ws.Write(replayBytes)
+			case "unsubscribe_logs":
+				app.wsHub.Unsubscribe(ws, msg.ID)
 			}
 		}
 	}
 }
 
+// WSClientMessage is a message sent by a client: ping, or subscribe_logs /
+// unsubscribe_logs for a remediation ID with the offset to replay from
+type WSClientMessage struct {
+	Type   string `json:"type"`
+	ID     string `json:"id,omitempty"`
+	Offset int    `json:"offset,omitempty"`
+}
+
 // BroadcastServiceUpdate sends a service update to all clients
 func (app *App) BroadcastServiceUpdate(service *Service) {
 	app.wsHub.Broadcast("service_update", service)
@@ -0,0 +1,21 @@
+const TOKEN_KEY = 'highline_token';
+
+// Picks up ?token=... from the page URL once and remembers it, so the dashboard
+// can be opened with an admin API key when backend authentication is enabled.
+export function getToken(): string | null {
+  const params = new URLSearchParams(window.location.search);
+  const fromUrl = params.get('token');
+  if (fromUrl) {
+    localStorage.setItem(TOKEN_KEY, fromUrl);
+    params.delete('token');
+    const query = params.toString();
+    window.history.replaceState(null, '', window.location.pathname + (query ? `?${query}` : ''));
+    return fromUrl;
+  }
+  return localStorage.getItem(TOKEN_KEY);
+}
+
+export function authHeaders(): HeadersInit {
+  const token = getToken();
+  return token ? { Authorization: `Bearer ${token}` } : {};
+}
@@ -1,5 +1,7 @@
-import { useState, useEffect } from 'react';
-import { RemediationRecord, RemediationStatus } from '../types';
+import { useState, useEffect, useRef } from 'react';
+import { RemediationDiff, RemediationRecord, RemediationStatus } from '../types';
+import { authHeaders, getToken } from '../auth';
+import { useRemediationLogs } from '../hooks/useRemediationLogs';
 
 interface RemediationsProps {
   onBack: () => void;
@@ -18,7 +20,7 @@ export default function Remediations({ onBack }: RemediationsProps) {
 
   const fetchRemediations = async () => {
     try {
-      const response = await fetch('/api/remediations');
+      const response = await fetch('/api/remediations', { headers: authHeaders() });
       if (response.ok) {
         const data = await response.json();
         setRemediations(data || []);
@@ -35,11 +37,12 @@ export default function Remediations({ onBack }: RemediationsProps) {
     : null;
 
   const statusConfig: Record<RemediationStatus, { color: string; bg: string; label: string; icon: string }> = {
-    pending: { color: 'text-gray-400', bg: 'bg-gray-400/10', label: 'Pending', icon: '⏳' },
+    pending: { color: 'text-gray-400', bg: 'bg-gray-400/10', label: 'Queued', icon: '⏳' },
     running: { color: 'text-blue-400', bg: 'bg-blue-400/10', label: 'Running', icon: '🔄' },
     success: { color: 'text-highline-accent', bg: 'bg-highline-accent/10', label: 'Success', icon: '✅' },
     failed: { color: 'text-highline-error', bg: 'bg-highline-error/10', label: 'Failed', icon: '❌' },
     timed_out: { color: 'text-highline-warning', bg: 'bg-highline-warning/10', label: 'Timed Out', icon: '⏰' },
+    cancelled: { color: 'text-gray-400', bg: 'bg-gray-400/10', label: 'Cancelled', icon: '🚫' },
   };
 
   return (
@@ -110,8 +113,12 @@ export default function Remediations({ onBack }: RemediationsProps) {
                       <div>{new Date(r.start_time).toLocaleTimeString()}</div>
                     </div>
                     <div className="bg-highline-bg rounded p-2">
-                      <div className="text-highline-muted">Duration</div>
-                      <div>{r.duration || 'Running...'}</div>
+                      <div className="text-highline-muted">{r.status === 'pending' ? 'Queue' : 'Duration'}</div>
+                      <div>
+                        {r.status === 'pending'
+                          ? `#${r.queue_position ?? '?'}${r.priority ? ` (priority ${r.priority})` : ''}`
+                          : r.duration || 'Running...'}
+                      </div>
                     </div>
                   </div>
 
@@ -128,7 +135,12 @@ export default function Remediations({ onBack }: RemediationsProps) {
           {/* Detail Panel */}
           <div className="lg:sticky lg:top-4 lg:self-start">
             {selectedRemediation ? (
-              <RemediationDetail remediation={selectedRemediation} statusConfig={statusConfig} />
+              <RemediationDetail
+                remediation={selectedRemediation}
+                statusConfig={statusConfig}
+                onChange={fetchRemediations}
+                onSelect={setSelectedId}
+              />
             ) : (
               <div className="bg-highline-card border border-highline-border rounded-xl p-8 text-center text-highline-muted">
                 Select a remediation to view details
@@ -141,13 +153,47 @@ export default function Remediations({ onBack }: RemediationsProps) {
   );
 }
 
+const phaseLabels: Record<string, string> = {
+  cloned: 'Cloned',
+  analyzing: 'Analyzing',
+  edited: 'Edited',
+  tests_run: 'Tests run',
+  committed: 'Committed',
+  pushed: 'Pushed',
+};
+
 interface RemediationDetailProps {
   remediation: RemediationRecord;
   statusConfig: Record<RemediationStatus, { color: string; bg: string; label: string; icon: string }>;
+  onChange: () => void;
+  onSelect: (id: string) => void;
 }
 
-function RemediationDetail({ remediation, statusConfig }: RemediationDetailProps) {
+function RemediationDetail({ remediation, statusConfig, onChange, onSelect }: RemediationDetailProps) {
   const config = statusConfig[remediation.status];
+  const [actionError, setActionError] = useState<string | null>(null);
+  const active = remediation.status === 'pending' || remediation.status === 'running';
+
+  const runAction = async (action: 'cancel' | 'retry') => {
+    setActionError(null);
+    try {
+      const response = await fetch(`/api/remediations/${remediation.id}/${action}`, {
+        method: 'POST',
+        headers: authHeaders(),
+      });
+      if (!response.ok) {
+        setActionError((await response.text()).trim());
+        return;
+      }
+      if (action === 'retry') {
+        const record: RemediationRecord = await response.json();
+        onSelect(record.id);
+      }
+      onChange();
+    } catch (err) {
+      console.error(`Failed to ${action} remediation:`, err);
+    }
+  };
 
   return (
     <div className="bg-highline-card border border-highline-border rounded-xl overflow-hidden">
@@ -160,15 +206,41 @@ function RemediationDetail({ remediation, statusConfig }: RemediationDetailProps
             <span className="text-sm font-medium">{config.label}</span>
           </div>
         </div>
-        <div className="text-xs text-highline-muted font-mono">ID: {remediation.id}</div>
+        <div className="flex items-center justify-between">
+          <div className="text-xs text-highline-muted font-mono">ID: {remediation.id}</div>
+          {active ? (
+            <button
+              onClick={() => runAction('cancel')}
+              className="px-3 py-1 text-xs rounded-lg border border-highline-error/40 text-highline-error hover:bg-highline-error/10 transition-colors"
+            >
+              Cancel
+            </button>
+          ) : (
+            <button
+              onClick={() => runAction('retry')}
+              className="px-3 py-1 text-xs rounded-lg border border-highline-border text-highline-muted hover:text-white hover:bg-highline-border transition-colors"
+            >
+              Retry
+            </button>
+          )}
+        </div>
+        {actionError && <div className="mt-2 text-xs text-highline-error">{actionError}</div>}
       </div>
 
       {/* Info Grid */}
       <div className="p-4 border-b border-highline-border grid grid-cols-2 gap-3">
         <InfoItem label="Repository" value={remediation.github_repo.replace('https://github.com/', '')} />
+        {remediation.repo_path && <InfoItem label="Path" value={`${remediation.repo_path}/`} />}
+        {remediation.base_branch && <InfoItem label="Base Branch" value={remediation.base_branch} />}
         <InfoItem label="Container" value={remediation.container_name || 'N/A'} />
         <InfoItem label="Started" value={new Date(remediation.start_time).toLocaleString()} />
         <InfoItem label="Duration" value={remediation.duration || 'Running...'} />
+        {remediation.triggered_by && (
+          <InfoItem
+            label="Triggered By"
+            value={remediation.retry_of ? `retry of ${remediation.retry_of}` : remediation.triggered_by}
+          />
+        )}
         {remediation.exit_code !== undefined && (
           <InfoItem label="Exit Code" value={String(remediation.exit_code)} />
         )}
@@ -180,8 +252,69 @@ function RemediationDetail({ remediation, statusConfig }: RemediationDetailProps
         <div className="bg-highline-bg rounded-lg p-3 text-xs font-mono text-highline-error/80 max-h-32 overflow-y-auto">
           {remediation.error_log}
         </div>
+        {remediation.prompt && (
+          <>
+            <div className="text-xs text-highline-muted uppercase tracking-wider mt-3 mb-2">Instructions</div>
+            <div className="bg-highline-bg rounded-lg p-3 text-xs font-mono max-h-32 overflow-y-auto whitespace-pre-wrap">
+              {remediation.prompt}
+            </div>
+          </>
+        )}
+        {remediation.context && (
+          <>
+            <div className="text-xs text-highline-muted uppercase tracking-wider mt-3 mb-2">Context</div>
+            <div className="text-xs text-highline-muted space-y-1">
+              {remediation.context.commit && (
+                <div>
+                  Deployed commit <span className="font-mono">{remediation.context.commit.slice(0, 12)}</span>
+                </div>
+              )}
+              <div>
+                {remediation.context.logs?.length || 0} log entries, {remediation.context.errors?.length || 0} recent errors
+              </div>
+              {remediation.context.stack_frames && remediation.context.stack_frames.length > 0 && (
+                <ul className="bg-highline-bg rounded-lg p-3 font-mono max-h-32 overflow-y-auto">
+                  {remediation.context.stack_frames.map((frame, i) => (
+                    <li key={i}>
+                      {frame.file}:{frame.line}
+                      {frame.function && <span className="text-highline-muted"> in {frame.function}</span>}
+                    </li>
+                  ))}
+                </ul>
+              )}
+            </div>
+          </>
+        )}
       </div>
 
+      {/* Phases */}
+      {remediation.phases && remediation.phases.length > 0 && (
+        <div className="p-4 border-b border-highline-border">
+          <div className="text-xs text-highline-muted uppercase tracking-wider mb-2">Progress</div>
+          <ol className="space-y-1">
+            {remediation.phases.map((event, i) => (
+              <li key={i} className="flex items-baseline gap-3 text-sm">
+                <span className="text-xs text-highline-muted font-mono w-20 shrink-0">
+                  {new Date(event.time).toLocaleTimeString()}
+                </span>
+                <span className="font-medium">{phaseLabels[event.phase] || event.phase}</span>
+                {event.detail && (
+                  <span className="text-xs text-highline-muted font-mono truncate" title={event.detail}>
+                    {event.detail}
+                  </span>
+                )}
+              </li>
+            ))}
+          </ol>
+        </div>
+      )}
+
+      {/* Diff */}
+      {remediation.diff && <DiffView remediationId={remediation.id} />}
+
+      {/* Agent Output */}
+      <AgentOutput remediationId={remediation.id} />
+
       {/* Agent Report */}
       {remediation.agent_report && (
         <div className="p-4">
@@ -224,6 +357,28 @@ function RemediationDetail({ remediation, statusConfig }: RemediationDetailProps
                 </div>
               )}
 
+              {remediation.agent_report.tests && (
+                <div>
+                  <div className="flex items-center gap-2">
+                    <span className="text-highline-muted">Tests:</span>
+                    <span className={remediation.agent_report.tests.result === 'failed' ? 'text-highline-error' : remediation.agent_report.tests.result === 'passed' ? 'text-highline-accent' : 'text-highline-muted'}>
+                      {remediation.agent_report.tests.result}
+                      {remediation.agent_report.tests.result !== 'skipped' && ` (exit ${remediation.agent_report.tests.exit_code})`}
+                    </span>
+                    {remediation.agent_report.tests.command && (
+                      <span className="font-mono text-xs bg-highline-border px-2 py-0.5 rounded">
+                        {remediation.agent_report.tests.command}
+                      </span>
+                    )}
+                  </div>
+                  {remediation.agent_report.tests.output && (
+                    <div className="mt-1 bg-highline-bg rounded p-2 text-xs font-mono max-h-48 overflow-y-auto whitespace-pre-wrap">
+                      {remediation.agent_report.tests.output}
+                    </div>
+                  )}
+                </div>
+              )}
+
               {remediation.agent_report.logs && (
                 <div className="mt-2">
                   <span className="text-highline-muted">Logs:</span>
@@ -248,6 +403,89 @@ function RemediationDetail({ remediation, statusConfig }: RemediationDetailProps
   );
 }
 
+function DiffView({ remediationId }: { remediationId: string }) {
+  const [diff, setDiff] = useState<RemediationDiff | null>(null);
+
+  useEffect(() => {
+    setDiff(null);
+    fetch(`/api/remediations/${remediationId}/diff`, { headers: authHeaders() })
+      .then(response => (response.ok ? response.json() : null))
+      .then(setDiff)
+      .catch(err => console.error('Failed to fetch diff:', err));
+  }, [remediationId]);
+
+  const lineColor = (line: string) => {
+    if (line.startsWith('+++') || line.startsWith('---')) return 'text-highline-muted';
+    if (line.startsWith('+')) return 'text-highline-accent';
+    if (line.startsWith('-')) return 'text-highline-error';
+    if (line.startsWith('@@')) return 'text-blue-400';
+    return '';
+  };
+
+  return (
+    <div className="p-4 border-b border-highline-border">
+      <div className="flex items-center justify-between mb-2">
+        <div className="text-xs text-highline-muted uppercase tracking-wider">Diff</div>
+        <a
+          href={`/api/remediations/${remediationId}/diff?format=patch${getToken() ? `&token=${encodeURIComponent(getToken()!)}` : ''}`}
+          target="_blank"
+          rel="noreferrer"
+          className="text-xs text-highline-muted hover:text-white"
+        >
+          Raw
+        </a>
+      </div>
+      {diff ? (
+        <>
+          <div className="mb-2 flex flex-wrap gap-1">
+            {diff.files.map(file => (
+              <span key={file} className="text-xs bg-highline-border px-2 py-0.5 rounded font-mono">
+                {file}
+              </span>
+            ))}
+          </div>
+          <div className="bg-highline-bg rounded-lg p-3 text-xs font-mono max-h-96 overflow-auto whitespace-pre">
+            {diff.patch.split('\n').map((line, i) => (
+              <div key={i} className={lineColor(line)}>{line || ' '}</div>
+            ))}
+            {diff.truncated && (
+              <div className="text-highline-muted">… diff truncated ({diff.bytes} bytes total)</div>
+            )}
+          </div>
+        </>
+      ) : (
+        <div className="text-xs text-highline-muted">Loading diff…</div>
+      )}
+    </div>
+  );
+}
+
+function AgentOutput({ remediationId }: { remediationId: string }) {
+  const { lines, truncated } = useRemediationLogs(remediationId);
+  const outputRef = useRef<HTMLDivElement>(null);
+
+  // Keep the newest output in view
+  useEffect(() => {
+    if (outputRef.current) {
+      outputRef.current.scrollTop = outputRef.current.scrollHeight;
+    }
+  }, [lines.length]);
+
+  return (
+    <div className="p-4 border-b border-highline-border">
+      <div className="text-xs text-highline-muted uppercase tracking-wider mb-2">Agent Output</div>
+      <div ref={outputRef} className="bg-highline-bg rounded-lg p-3 text-xs font-mono max-h-64 overflow-y-auto whitespace-pre-wrap">
+        {truncated && <div className="text-highline-muted">… earlier output dropped</div>}
+        {lines.length === 0 ? (
+          <div className="text-highline-muted">No output yet.</div>
+        ) : (
+          lines.map(line => <div key={line.offset}>{line.text}</div>)
+        )}
+      </div>
+    </div>
+  );
+}
+
 function InfoItem({ label, value }: { label: string; value: string }) {
   return (
     <div className="bg-highline-bg rounded-lg p-2">
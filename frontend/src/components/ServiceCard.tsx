@@ -45,10 +45,25 @@ export default function ServiceCard({ service, isSelected, onClick, currentTime
         </svg>
       ),
     },
+    pending: {
+      color: 'text-highline-muted',
+      bg: 'bg-highline-muted/10',
+      border: 'border-highline-border',
+      glow: '',
+      label: 'Pending',
+      icon: (
+        <svg className="w-4 h-4" fill="none" viewBox="0 0 24 24" stroke="currentColor">
+          <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M12 8v4l3 3m6-3a9 9 0 11-18 0 9 9 0 0118 0z" />
+        </svg>
+      ),
+    },
   };
 
   const config = statusConfig[service.status] || statusConfig.down;
-  const lastHeartbeat = service.last_heartbeat ? new Date(service.last_heartbeat) : null;
+  // Registered services that never reported have a zero-value timestamp
+  const lastHeartbeat = service.last_heartbeat && !service.last_heartbeat.startsWith('0001-')
+    ? new Date(service.last_heartbeat)
+    : null;
   const timeAgo = lastHeartbeat ? getTimeAgo(lastHeartbeat, currentTime) : 'Never';
 
   return (
@@ -69,6 +84,7 @@ export default function ServiceCard({ service, isSelected, onClick, currentTime
               onClick={(e) => e.stopPropagation()}
             >
               {service.github_repo.replace('https://github.com/', '')}
+              {service.repo_path && `/${service.repo_path}`}
             </a>
           )}
         </div>
@@ -1,4 +1,6 @@
+import { useState } from 'react';
 import { Service } from '../types';
+import { authHeaders } from '../auth';
 
 interface ServiceLogsProps {
   service: Service;
@@ -8,6 +10,21 @@ interface ServiceLogsProps {
 export default function ServiceLogs({ service, onClose }: ServiceLogsProps) {
   // Use logs from backend, sorted newest first
   const logs = buildLogTimeline(service);
+  const [remediateStatus, setRemediateStatus] = useState<string | null>(null);
+
+  // Queue a remediation for the service's last error
+  const remediate = async () => {
+    setRemediateStatus(null);
+    try {
+      const response = await fetch(`/api/services/${encodeURIComponent(service.name)}/remediate`, {
+        method: 'POST',
+        headers: authHeaders(),
+      });
+      setRemediateStatus(response.ok ? 'Remediation queued' : (await response.text()).trim());
+    } catch (err) {
+      console.error('Failed to request remediation:', err);
+    }
+  };
 
   return (
     <div className="mt-6 bg-highline-card border border-highline-border rounded-xl overflow-hidden">
@@ -24,14 +41,25 @@ export default function ServiceLogs({ service, onClose }: ServiceLogsProps) {
             <p className="text-xs text-highline-muted">Activity Log</p>
           </div>
         </div>
-        <button 
-          onClick={onClose}
-          className="p-2 text-highline-muted hover:text-white hover:bg-highline-border rounded-lg transition-colors"
-        >
-          <svg className="w-5 h-5" fill="none" viewBox="0 0 24 24" stroke="currentColor">
-            <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M6 18L18 6M6 6l12 12" />
-          </svg>
-        </button>
+        <div className="flex items-center gap-2">
+          {remediateStatus && <span className="text-xs text-highline-muted">{remediateStatus}</span>}
+          {service.github_repo && (
+            <button
+              onClick={remediate}
+              className="px-3 py-1.5 text-xs rounded-lg border border-highline-border text-highline-muted hover:text-white hover:bg-highline-border transition-colors"
+            >
+              Remediate
+            </button>
+          )}
+          <button 
+            onClick={onClose}
+            className="p-2 text-highline-muted hover:text-white hover:bg-highline-border rounded-lg transition-colors"
+          >
+            <svg className="w-5 h-5" fill="none" viewBox="0 0 24 24" stroke="currentColor">
+              <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M6 18L18 6M6 6l12 12" />
+            </svg>
+          </button>
+        </div>
       </div>
 
       {/* Log Table */}
@@ -0,0 +1,73 @@
+import { useEffect, useState } from 'react';
+import { RemediationLogBatch, RemediationLogLine } from '../types';
+import { getToken } from '../auth';
+
+interface UseRemediationLogsReturn {
+  lines: RemediationLogLine[];
+  truncated: boolean;
+}
+
+// Follows a remediation's agent output over its own WebSocket, resuming from
+// the last received offset after a reconnect
+export function useRemediationLogs(remediationId: string): UseRemediationLogsReturn {
+  const [lines, setLines] = useState<RemediationLogLine[]>([]);
+  const [truncated, setTruncated] = useState(false);
+
+  useEffect(() => {
+    setLines([]);
+    setTruncated(false);
+
+    let next = 0;
+    let ws: WebSocket | null = null;
+    let reconnectTimeout: number | null = null;
+    let closed = false;
+
+    const connect = () => {
+      const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
+      const token = getToken();
+      ws = new WebSocket(`${protocol}//${window.location.host}/ws${token ? `?token=${encodeURIComponent(token)}` : ''}`);
+
+      ws.onopen = () => {
+        ws?.send(JSON.stringify({ type: 'subscribe_logs', id: remediationId, offset: next }));
+      };
+
+      ws.onmessage = (event) => {
+        try {
+          const msg = JSON.parse(event.data);
+          if (msg.type !== 'remediation_log') return;
+          const batch = msg.data as RemediationLogBatch;
+          if (batch.id !== remediationId) return;
+
+          // Replayed and live lines can overlap or arrive out of order;
+          // keep each offset once, sorted
+          if (batch.lines.length > 0) {
+            next = Math.max(next, batch.next);
+            setLines(prev => {
+              const merged = [...prev, ...batch.lines].sort((a, b) => a.offset - b.offset);
+              return merged.filter((l, i) => i === 0 || l.offset !== merged[i - 1].offset);
+            });
+          }
+          if (batch.truncated) setTruncated(true);
+        } catch (e) {
+          console.error('[WS] Failed to parse log message:', e);
+        }
+      };
+
+      ws.onclose = () => {
+        if (!closed) {
+          reconnectTimeout = window.setTimeout(connect, 3000);
+        }
+      };
+    };
+
+    connect();
+
+    return () => {
+      closed = true;
+      if (reconnectTimeout) clearTimeout(reconnectTimeout);
+      ws?.close();
+    };
+  }, [remediationId]);
+
+  return { lines, truncated };
+}
@@ -1,9 +1,10 @@
 import { useEffect, useRef, useState, useCallback } from 'react';
 import { Service } from '../types';
+import { getToken } from '../auth';
 
 interface WSMessage {
-  type: 'init' | 'services' | 'service_update' | 'pong';
-  data: Service[] | Service | null;
+  type: 'init' | 'services' | 'service_update' | 'service_removed' | 'pong';
+  data: Service[] | Service | { name: string } | null;
 }
 
 interface UseWebSocketReturn {
@@ -24,7 +25,8 @@ export function useWebSocket(): UseWebSocketReturn {
   const getWebSocketUrl = useCallback(() => {
     const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
     const host = window.location.host;
-    return `${protocol}//${host}/ws`;
+    const token = getToken();
+    return `${protocol}//${host}/ws${token ? `?token=${encodeURIComponent(token)}` : ''}`;
   }, []);
 
   const connect = useCallback(() => {
@@ -84,6 +86,12 @@ export function useWebSocket(): UseWebSocketReturn {
             });
             break;
             
+          case 'service_removed':
+            // Service was deleted - drop it from the list
+            const removedName = (msg.data as { name: string }).name;
+            setServices(prev => prev.filter(s => s.name !== removedName));
+            break;
+            
           case 'pong':
             // Heartbeat response, ignore
             break;
@@ -9,17 +9,34 @@ export interface LogEntry {
 export interface Service {
   name: string;
   github_repo: string;
-  status: 'healthy' | 'error' | 'down';
+  repo_path?: string;
+  base_branch?: string;
+  status: 'healthy' | 'error' | 'down' | 'pending';
   last_heartbeat: string;
   last_error?: string;
+  commit?: string;
   uptime_percent: number;
   total_checks: number;
   success_checks: number;
   remediation_log?: string[];
   logs?: LogEntry[];
+  registered: boolean;
+  owner?: string;
+  tags?: string[];
+  environment?: string;
+  description?: string;
+  policy_decision?: PolicyDecision;
 }
 
-export type RemediationStatus = 'pending' | 'running' | 'success' | 'failed' | 'timed_out';
+export interface PolicyDecision {
+  time: string;
+  action: 'trigger' | 'skip';
+  reason: string;
+  mode?: 'auto' | 'suggest' | 'off';
+  signature?: string;
+}
+
+export type RemediationStatus = 'pending' | 'running' | 'success' | 'failed' | 'timed_out' | 'cancelled';
 
 export interface AgentReport {
   remediation_id: string;
@@ -31,13 +48,42 @@ export interface AgentReport {
   pushed: boolean;
   logs?: string;
   error_details?: string;
+  tests?: TestRun;
   timestamp: string;
 }
 
+export interface TestRun {
+  command?: string;
+  result: 'passed' | 'failed' | 'skipped';
+  exit_code: number;
+  output?: string;
+}
+
+export interface StackFrame {
+  file: string;
+  line: number;
+  function?: string;
+}
+
+export interface RemediationContext {
+  commit?: string;
+  stack_frames?: StackFrame[];
+  errors?: LogEntry[];
+  logs?: LogEntry[];
+}
+
+export interface TestPolicy {
+  command: string;
+  on_failure?: 'block' | 'push';
+  timeout?: string;
+}
+
 export interface RemediationRecord {
   id: string;
   service_name: string;
   github_repo: string;
+  repo_path?: string;
+  base_branch?: string;
   error_log: string;
   status: RemediationStatus;
   container_id?: string;
@@ -48,4 +94,58 @@ export interface RemediationRecord {
   exit_code?: number;
   agent_report?: AgentReport;
   error_message?: string;
+  mode?: 'auto' | 'suggest' | 'off';
+  error_signature?: string;
+  priority: number;
+  queue_position?: number;
+  started_at?: string;
+  agent_provider?: string;
+  agent_model?: string;
+  prompt?: string;
+  test_policy?: TestPolicy;
+  context?: RemediationContext;
+  triggered_by?: 'policy' | 'manual' | 'retry';
+  retry_of?: string;
+  pr_number?: number;
+  pr_url?: string;
+  pr_error?: string;
+  pr_state?: 'open' | 'merged' | 'closed';
+  merged_at?: string;
+  status_at_merge?: ServiceStatus;
+  verification?: 'pending' | 'verified_fix' | 'no_effect' | 'regressed';
+  verified_at?: string;
+  diff?: RemediationDiffSummary;
+  phases?: PhaseEvent[];
+}
+
+export type AgentPhase = 'cloned' | 'analyzing' | 'edited' | 'tests_run' | 'committed' | 'pushed';
+
+export interface PhaseEvent {
+  phase: AgentPhase;
+  time: string;
+  detail?: string;
+}
+
+export interface RemediationDiffSummary {
+  files: string[];
+  bytes: number;
+  truncated: boolean;
+}
+
+export interface RemediationDiff extends RemediationDiffSummary {
+  id: string;
+  patch: string;
+}
+
+export interface RemediationLogLine {
+  offset: number;
+  time: string;
+  text: string;
+}
+
+export interface RemediationLogBatch {
+  id: string;
+  lines: RemediationLogLine[];
+  next: number;
+  truncated?: boolean;
 }
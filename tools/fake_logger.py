@@ -8,6 +8,7 @@ Simulates a Twitter-like service sending heartbeats with various events:
 - File uploads (large - causes failures ~10% of the time)
 """
 
+import os
 import requests
 import random
 import time
@@ -18,6 +19,7 @@ from datetime import datetime
 BACKEND_URL = "http://localhost:8080"
 SERVICE_NAME = "trivial-service"
 GITHUB_REPO = "https://github.com/AlexG28/trivialExample"
+INGEST_TOKEN = os.environ.get("HIGHLINE_TOKEN", "")  # required when the backend has auth enabled
 
 # Fake data
 USERNAMES = [
@@ -110,9 +112,11 @@ def send_heartbeat(data):
     }
     
     try:
+        headers = {"Authorization": f"Bearer {INGEST_TOKEN}"} if INGEST_TOKEN else {}
         response = requests.post(
             f"{BACKEND_URL}/heartbeat",
             json=payload,
+            headers=headers,
             timeout=5
         )
         return response.status_code == 200
@@ -0,0 +1,120 @@
+#!/usr/bin/env python3
+"""
+Mock OpenAI-compatible LLM endpoint for Highline
+
+Answers /v1/chat/completions (streaming and non-streaming) and /v1/models with a
+canned reply, so the remediation flow can run without a real model:
+
+    python mock_llm.py --port 9090
+    AGENT_BASE_URL=http://localhost:9090/v1 CEREBRAS_API_KEY=mock ...
+
+Any API key is accepted.
+"""
+
+import argparse
+import json
+import time
+from http.server import BaseHTTPRequestHandler, ThreadingHTTPServer
+
+REPLY = "I looked at the error but this mock model does not make changes."
+MODEL = "mock-model"
+
+
+def completion(model, content):
+    return {
+        "id": f"chatcmpl-mock-{int(time.time() * 1000)}",
+        "object": "chat.completion",
+        "created": int(time.time()),
+        "model": model,
+        "choices": [{
+            "index": 0,
+            "message": {"role": "assistant", "content": content},
+            "finish_reason": "stop",
+        }],
+        "usage": {"prompt_tokens": 0, "completion_tokens": 0, "total_tokens": 0},
+    }
+
+
+def chunk(model, delta, finish_reason=None):
+    return {
+        "id": "chatcmpl-mock",
+        "object": "chat.completion.chunk",
+        "created": int(time.time()),
+        "model": model,
+        "choices": [{"index": 0, "delta": delta, "finish_reason": finish_reason}],
+    }
+
+
+class Handler(BaseHTTPRequestHandler):
+    def send_json(self, status, body):
+        data = json.dumps(body).encode()
+        self.send_response(status)
+        self.send_header("Content-Type", "application/json")
+        self.send_header("Content-Length", str(len(data)))
+        self.end_headers()
+        self.wfile.write(data)
+
+    def do_GET(self):
+        if self.path.rstrip("/").endswith("/models"):
+            self.send_json(200, {"object": "list", "data": [{"id": MODEL, "object": "model"}]})
+        else:
+            self.send_json(404, {"error": {"message": "not found"}})
+
+    def do_POST(self):
+        if not self.path.rstrip("/").endswith("/chat/completions"):
+            self.send_json(404, {"error": {"message": "not found"}})
+            return
+
+        length = int(self.headers.get("Content-Length", 0))
+        try:
+            request = json.loads(self.rfile.read(length) or b"{}")
+        except json.JSONDecodeError:
+            self.send_json(400, {"error": {"message": "invalid JSON"}})
+            return
+
+        model = request.get("model", MODEL)
+        messages = request.get("messages", [])
+        last = messages[-1].get("content", "") if messages else ""
+        print(f"📨 {model}: {str(last)[:80]!r}")
+
+        if not request.get("stream"):
+            self.send_json(200, completion(model, REPLY))
+            return
+
+        self.send_response(200)
+        self.send_header("Content-Type", "text/event-stream")
+        self.send_header("Cache-Control", "no-cache")
+        self.end_headers()
+        events = [
+            chunk(model, {"role": "assistant", "content": ""}),
+            chunk(model, {"content": REPLY}),
+            chunk(model, {}, "stop"),
+        ]
+        for event in events:
+            self.wfile.write(f"data: {json.dumps(event)}\n\n".encode())
+        self.wfile.write(b"data: [DONE]\n\n")
+        self.wfile.flush()
+
+    def log_message(self, format, *args):
+        pass
+
+
+def main():
+    global REPLY
+
+    parser = argparse.ArgumentParser(description="Mock OpenAI-compatible LLM endpoint")
+    parser.add_argument("--port", type=int, default=9090, help="Port to listen on")
+    parser.add_argument("--reply", default=REPLY, help="Text returned for every completion")
+    args = parser.parse_args()
+    REPLY = args.reply
+
+    server = ThreadingHTTPServer(("0.0.0.0", args.port), Handler)
+    print(f"🤖 Mock LLM listening on http://localhost:{args.port}/v1")
+    try:
+        server.serve_forever()
+    except KeyboardInterrupt:
+        print("\n👋 Stopped")
+
+
+if __name__ == "__main__":
+    main()